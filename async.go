@@ -0,0 +1,356 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/syslog"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by LogE and LogfE when a message is written to
+// the default log because the queue of messages waiting to be sent to syslog
+// is full, see WithAsync.
+var ErrQueueFull = errors.New("slog: message queue is full")
+
+// ErrDropped is returned by LogE and LogfE when a message is discarded
+// according to the policy set with WithOverflowPolicy.
+var ErrDropped = errors.New("slog: message dropped")
+
+// OverflowPolicy tells what happens to a message when the queue of messages
+// waiting to be sent to syslog is full, see WithAsync.
+type OverflowPolicy int
+
+const (
+	// OverflowFallback writes the message to the default log. This is the
+	// default policy.
+	OverflowFallback OverflowPolicy = iota
+
+	// OverflowBlock makes the caller wait until there is space in the queue.
+	OverflowBlock
+
+	// OverflowDropNewest discards the message.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest message in the queue to make
+	// space for the new one.
+	OverflowDropOldest
+)
+
+// asyncItem is a message waiting in the queue of asyncBackend or a request
+// to report when the preceding messages are sent.
+type asyncItem struct {
+	p       *params
+	r       *record
+	flushed chan struct{}
+}
+
+// asyncBackend sends messages from a background goroutine, see WithAsync.
+type asyncBackend struct {
+	b         backend
+	queue     chan asyncItem
+	urgent    chan asyncItem // Nil unless WithPriorityQueue is given.
+	done      chan struct{}  // Closed when the background goroutine exits.
+	batchSize int            // Maximum number of messages sent at once.
+	linger    time.Duration  // Maximum time to wait for a batch to fill up.
+	overflow  OverflowPolicy
+	drop      dropFunc
+	latency   latencyGauge // Measured for WithAdaptiveSampling only.
+
+	mu      sync.Mutex      // Protects evicted.
+	evicted []chan struct{} // Flush requests taken out of the queue by evict.
+
+	// failedSyslogWarningDone is accessed by the background goroutine only.
+	failedSyslogWarningDone bool
+}
+
+func newAsyncBackend(b backend, p *params, drop dropFunc) *asyncBackend {
+	a := &asyncBackend{
+		b:         b,
+		queue:     make(chan asyncItem, p.queueSize),
+		done:      make(chan struct{}),
+		batchSize: p.batchSize,
+		linger:    p.batchLinger,
+		overflow:  p.overflow,
+		drop:      drop,
+	}
+	if a.batchSize < 1 {
+		a.batchSize = 1
+	}
+	if p.priorityQueue {
+		a.urgent = make(chan asyncItem, p.queueSize/4+1)
+	}
+	go a.run()
+	return a
+}
+
+// urgentSeverity is the least important severity of messages which go to
+// the urgent queue, see WithPriorityQueue.
+const urgentSeverity = syslog.LOG_CRIT
+
+// inbox receives items from the queues of asyncBackend preferring urgent
+// ones. It is used by the background goroutine only.
+type inbox struct {
+	queue  <-chan asyncItem
+	urgent <-chan asyncItem // Nil when there is no urgent queue or it is closed.
+}
+
+// receive returns the next item. If wait is false, it returns immediately
+// when the queues are empty, otherwise it waits until an item arrives or
+// timeout, if not nil, fires. It reports whether an item has been received
+// and whether the queues are still open.
+func (in *inbox) receive(wait bool, timeout <-chan time.Time) (it asyncItem, received, open bool) {
+	for in.urgent != nil {
+		select {
+		case it, ok := <-in.urgent:
+			if ok {
+				return it, true, true
+			}
+			in.urgent = nil
+			continue
+		default:
+		}
+		if !wait {
+			break
+		}
+		select {
+		case it, ok := <-in.urgent:
+			if ok {
+				return it, true, true
+			}
+			in.urgent = nil
+		case it, ok := <-in.queue:
+			return it, ok, ok
+		case <-timeout:
+			return it, false, true
+		}
+	}
+	if !wait {
+		select {
+		case it, ok := <-in.queue:
+			return it, ok, ok
+		default:
+			return it, false, true
+		}
+	}
+	select {
+	case it, ok := <-in.queue:
+		return it, ok, ok
+	case <-timeout:
+		return it, false, true
+	}
+}
+
+func (a *asyncBackend) run() {
+	defer close(a.done)
+	defer a.flushEvicted()
+	in := &inbox{queue: a.queue, urgent: a.urgent}
+	var batch []*record
+	for {
+		it, received, open := in.receive(true, nil)
+		if !open {
+			return
+		}
+		if !received {
+			continue
+		}
+		if it.flushed != nil {
+			a.flushEvicted()
+			close(it.flushed)
+			continue
+		}
+		batch = append(batch[:0], it.r)
+		flushed, open := a.fill(in, &batch)
+		a.deliver(it.p, batch)
+		a.flushEvicted()
+		if flushed != nil {
+			close(flushed)
+		}
+		if !open {
+			return
+		}
+	}
+}
+
+// flushEvicted completes the flush requests taken out of the queue by evict.
+// It is called by the background goroutine when the messages it has taken
+// are sent, which include all messages queued before the requests.
+func (a *asyncBackend) flushEvicted() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, flushed := range a.evicted {
+		close(flushed)
+	}
+	a.evicted = a.evicted[:0]
+}
+
+// fill adds messages arriving within the linger time to batch until it is
+// full. It stops early at a flush request and returns it, and it reports
+// whether the queues are still open.
+func (a *asyncBackend) fill(in *inbox, batch *[]*record) (chan struct{}, bool) {
+	if len(*batch) >= a.batchSize {
+		return nil, true
+	}
+	var timeout <-chan time.Time
+	if a.linger > 0 {
+		t := time.NewTimer(a.linger)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for len(*batch) < a.batchSize {
+		// Without linger take only what is already queued.
+		it, received, open := in.receive(timeout != nil, timeout)
+		switch {
+		case !open:
+			return nil, false
+		case !received:
+			return nil, true
+		case it.flushed != nil:
+			return it.flushed, true
+		}
+		*batch = append(*batch, it.r)
+	}
+	return nil, true
+}
+
+// deliver sends rs falling back to the default log like Logger.send does.
+func (a *asyncBackend) deliver(p *params, rs []*record) {
+	var start time.Time
+	if p.sampling.maxLatency > 0 {
+		start = time.Now()
+	}
+	err := sendBatch(a.b, p, rs)
+	if !start.IsZero() {
+		a.latency.observe(start)
+	}
+	if err == nil || err == ErrRateLimited {
+		// Messages exceeding the limits are counted by the backend.
+		a.failedSyslogWarningDone = false
+		return
+	}
+	a.drop(p, len(rs), DropSendFailed)
+	if p.errorHandler != nil && err != ErrCircuitOpen {
+		// The circuit is reported by WithCircuitHandler.
+		p.errorHandler(err)
+	}
+	if !a.failedSyslogWarningDone {
+		log.Print("Error sending message to syslog: ", err)
+		a.failedSyslogWarningDone = true
+	}
+	for _, r := range rs {
+		log.Print(r.text())
+	}
+}
+
+func (a *asyncBackend) send(p *params, r *record) error {
+	it := asyncItem{p: p, r: r.clone()}
+	if a.offer(it) {
+		return nil
+	}
+	if a.urgent != nil && r.severity <= urgentSeverity && a.evict() && a.offer(it) {
+		// Important messages survive at the expense of others.
+		return nil
+	}
+	switch a.overflow {
+	case OverflowBlock:
+		a.queue <- it
+		return nil
+	case OverflowDropNewest:
+		return ErrDropped
+	case OverflowDropOldest:
+		for !a.offer(it) {
+			a.evict()
+		}
+		return nil
+	}
+	return ErrQueueFull
+}
+
+// offer queues it if there is space for it.
+func (a *asyncBackend) offer(it asyncItem) bool {
+	if a.urgent != nil && it.r.severity <= urgentSeverity {
+		select {
+		case a.urgent <- it:
+			return true
+		default:
+		}
+	}
+	select {
+	case a.queue <- it:
+		return true
+	default:
+		return false
+	}
+}
+
+// evict discards the oldest item of the queue of messages which are not
+// urgent. It reports whether there was one. Flush requests are not discarded
+// but left to the background goroutine outside of the queue.
+func (a *asyncBackend) evict() bool {
+	select {
+	case old := <-a.queue:
+		if old.flushed != nil {
+			// Messages queued before the flush request have been taken
+			// by the background goroutine already, but they may still
+			// be waiting for the batch to fill up or being sent.
+			a.mu.Lock()
+			a.evicted = append(a.evicted, old.flushed)
+			a.mu.Unlock()
+		} else {
+			a.drop(old.p, 1, DropOverflow)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// trySend queues r if there is space in the queue regardless of the overflow
+// policy.
+func (a *asyncBackend) trySend(p *params, r *record) error {
+	if a.offer(asyncItem{p: p, r: r.clone()}) {
+		return nil
+	}
+	return ErrQueueFull
+}
+
+// flush waits until messages queued before the call are sent.
+func (a *asyncBackend) flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case a.queue <- asyncItem{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close sends the queued messages and closes the underlying backend.
+func (a *asyncBackend) close() error {
+	close(a.queue)
+	if a.urgent != nil {
+		close(a.urgent)
+	}
+	<-a.done
+	return a.b.close()
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"log/syslog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateBackend is a backend which holds messages until gate is closed.
+type gateBackend struct {
+	gate    chan struct{}
+	entered chan struct{} // Receives a value when a send starts waiting.
+
+	mu   sync.Mutex
+	msgs []string
+}
+
+func newGateBackend() *gateBackend {
+	return &gateBackend{gate: make(chan struct{}), entered: make(chan struct{}, 1)}
+}
+
+func (b *gateBackend) send(p *params, r *record) error {
+	select {
+	case b.entered <- struct{}{}:
+	default:
+	}
+	<-b.gate
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, r.msg)
+	return nil
+}
+
+func (b *gateBackend) close() error {
+	return nil
+}
+
+func TestAsyncOverflow(t *testing.T) {
+	type message struct {
+		severity syslog.Priority
+		msg      string
+	}
+	info := func(msg string) message { return message{syslog.LOG_INFO, msg} }
+	crit := func(msg string) message { return message{syslog.LOG_CRIT, msg} }
+	tests := []struct {
+		name     string
+		policy   OverflowPolicy
+		priority bool
+		extra    []message // Sent when the queue is full.
+		blocks   bool      // Whether sending extra waits for the queue.
+		wantErrs []error
+		want     []string
+		dropped  int
+	}{
+		{
+			name:     "fallback",
+			policy:   OverflowFallback,
+			extra:    []message{info("m3")},
+			wantErrs: []error{ErrQueueFull},
+			want:     []string{"m0", "m1", "m2"},
+		},
+		{
+			name:     "block",
+			policy:   OverflowBlock,
+			extra:    []message{info("m3")},
+			blocks:   true,
+			wantErrs: []error{nil},
+			want:     []string{"m0", "m1", "m2", "m3"},
+		},
+		{
+			name:     "drop newest",
+			policy:   OverflowDropNewest,
+			extra:    []message{info("m3")},
+			wantErrs: []error{ErrDropped},
+			want:     []string{"m0", "m1", "m2"},
+		},
+		{
+			name:     "drop oldest",
+			policy:   OverflowDropOldest,
+			extra:    []message{info("m3"), info("m4")},
+			wantErrs: []error{nil, nil},
+			want:     []string{"m0", "m3", "m4"},
+			dropped:  2,
+		},
+		{
+			name:     "urgent queue",
+			priority: true,
+			extra:    []message{crit("c1")},
+			wantErrs: []error{nil},
+			want:     []string{"m0", "c1", "m1", "m2"},
+		},
+		{
+			name:     "urgent queue full",
+			priority: true,
+			extra:    []message{crit("c1"), crit("c2")},
+			wantErrs: []error{nil, nil},
+			want:     []string{"m0", "c1", "m2", "c2"},
+			dropped:  1,
+		},
+		{
+			name:     "not urgent",
+			priority: true,
+			extra:    []message{info("m3")},
+			wantErrs: []error{ErrQueueFull},
+			want:     []string{"m0", "m1", "m2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &params{queueSize: 2, overflow: tt.policy, priorityQueue: tt.priority}
+			b := newGateBackend()
+			dropped := 0
+			a := newAsyncBackend(b, p, func(p *params, n int, reason DropReason) {
+				if reason == DropOverflow {
+					dropped += n
+				}
+			})
+			// m0 is taken by the background goroutine, m1 and m2
+			// fill the queue.
+			if err := a.send(p, &record{severity: syslog.LOG_INFO, msg: "m0"}); err != nil {
+				t.Fatal(err)
+			}
+			<-b.entered
+			for _, msg := range []string{"m1", "m2"} {
+				if err := a.send(p, &record{severity: syslog.LOG_INFO, msg: msg}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var errs []error
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for _, m := range tt.extra {
+					errs = append(errs, a.send(p, &record{severity: m.severity, msg: m.msg}))
+				}
+			}()
+			if tt.blocks {
+				select {
+				case <-done:
+					t.Error("send returned while the queue was full")
+				case <-time.After(100 * time.Millisecond):
+				}
+			} else {
+				<-done
+			}
+			close(b.gate)
+			<-done
+			if err := a.close(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("errors = %v, want %v", errs, tt.wantErrs)
+			}
+			if !reflect.DeepEqual(b.msgs, tt.want) {
+				t.Errorf("sent %q, want %q", b.msgs, tt.want)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("dropped %d messages, want %d", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestAsyncFlushNotEvicted(t *testing.T) {
+	p := &params{queueSize: 2, overflow: OverflowDropOldest}
+	b := newGateBackend()
+	dropped := 0
+	a := newAsyncBackend(b, p, func(p *params, n int, reason DropReason) {
+		dropped += n
+	})
+	if err := a.send(p, &record{severity: syslog.LOG_INFO, msg: "m0"}); err != nil {
+		t.Fatal(err)
+	}
+	<-b.entered
+	flushed := make(chan error, 1)
+	go func() {
+		flushed <- a.flush(context.Background())
+	}()
+	for len(a.queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The flush request is the oldest item when m2 makes space for itself.
+	for _, msg := range []string{"m1", "m2"} {
+		if err := a.send(p, &record{severity: syslog.LOG_INFO, msg: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case err := <-flushed:
+		t.Fatalf("flush returned %v while m0 was being sent", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(b.gate)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if err := a.close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"m0", "m1", "m2"}; !reflect.DeepEqual(b.msgs, want) {
+		t.Errorf("sent %q, want %q", b.msgs, want)
+	}
+	if dropped != 0 {
+		t.Errorf("dropped %d messages, want none", dropped)
+	}
+}
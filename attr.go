@@ -0,0 +1,314 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Attr is a key-value pair attached to a structured message. Attrs can be
+// passed to Logw and friends in place of a key and its value. Unlike plain
+// values, common types stored in Attr are not boxed into interface{}.
+type Attr struct {
+	Key   string
+	Value Value
+}
+
+// Kind is the type of a Value.
+type Kind int
+
+// Kinds of values.
+const (
+	KindAny Kind = iota
+	KindString
+	KindInt64
+	KindUint64
+	KindFloat64
+	KindBool
+	KindDuration
+	KindTime
+	KindGroup
+)
+
+// Value is a value of an Attr. The zero Value is a KindAny value holding nil.
+type Value struct {
+	kind Kind
+	num  uint64      // Numeric kinds, bool, Unix time in nanoseconds, sdFlag.
+	str  string      // KindString.
+	any  interface{} // KindAny, *time.Location of KindTime, []Attr of KindGroup.
+}
+
+// String returns an Attr with a string value.
+func String(key, value string) Attr {
+	return Attr{key, Value{kind: KindString, str: value}}
+}
+
+// Int returns an Attr with an int value.
+func Int(key string, value int) Attr {
+	return Int64(key, int64(value))
+}
+
+// Int64 returns an Attr with an int64 value.
+func Int64(key string, value int64) Attr {
+	return Attr{key, Value{kind: KindInt64, num: uint64(value)}}
+}
+
+// Uint64 returns an Attr with an uint64 value.
+func Uint64(key string, value uint64) Attr {
+	return Attr{key, Value{kind: KindUint64, num: value}}
+}
+
+// Float64 returns an Attr with a float64 value.
+func Float64(key string, value float64) Attr {
+	return Attr{key, Value{kind: KindFloat64, num: math.Float64bits(value)}}
+}
+
+// Bool returns an Attr with a bool value.
+func Bool(key string, value bool) Attr {
+	var n uint64
+	if value {
+		n = 1
+	}
+	return Attr{key, Value{kind: KindBool, num: n}}
+}
+
+// Duration returns an Attr with a time.Duration value.
+func Duration(key string, value time.Duration) Attr {
+	return Attr{key, Value{kind: KindDuration, num: uint64(value)}}
+}
+
+// Time returns an Attr with a time.Time value. Monotonic clock reading is
+// discarded.
+func Time(key string, value time.Time) Attr {
+	return Attr{key, Value{kind: KindTime, num: uint64(value.UnixNano()), any: value.Location()}}
+}
+
+// maxErrCauses limits the number of causes recorded by ErrAttr.
+const maxErrCauses = 32
+
+// ErrAttr returns a group Attr under "error" key which records the message
+// and the type of err. If err wraps other errors (see errors.Unwrap and
+// errors.Join), their messages and types are recorded in "causes" group in
+// depth-first order, e.g.
+//
+//	error.msg="open config: no such file" error.type=*fmt.wrapError
+//	error.causes.0.msg="no such file" error.causes.0.type=*fs.PathError
+//
+// A nil err is recorded as "<nil>" message without type.
+func ErrAttr(err error) Attr {
+	if err == nil {
+		return Group("error", String("msg", "<nil>"))
+	}
+	attrs := errAttrs(err)
+	var causes []Attr
+	for _, c := range errCauses(err, nil) {
+		causes = append(causes, Group(strconv.Itoa(len(causes)), errAttrs(c)...))
+	}
+	if len(causes) > 0 {
+		attrs = append(attrs, Group("causes", causes...))
+	}
+	return Group("error", attrs...)
+}
+
+func errAttrs(err error) []Attr {
+	return []Attr{
+		String("msg", err.Error()),
+		String("type", fmt.Sprintf("%T", err)),
+	}
+}
+
+// errCauses appends errors wrapped by err to causes in depth-first order.
+func errCauses(err error, causes []error) []error {
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if u := e.Unwrap(); u != nil {
+			wrapped = []error{u}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+	for _, w := range wrapped {
+		if w == nil || len(causes) == maxErrCauses {
+			continue
+		}
+		causes = errCauses(w, append(causes, w))
+	}
+	return causes
+}
+
+// Group returns an Attr which groups attrs under key, so attributes of
+// different subsystems do not collide. Grouped attributes are rendered with
+// keys prefixed by the group key, e.g.
+//
+//	slog.Group("http", slog.Int("status", 200))
+//
+// is rendered as http.status=200. Structured output formats render groups in
+// their own way, e.g. as nested objects. Empty groups are omitted.
+func Group(key string, attrs ...Attr) Attr {
+	return Attr{key, Value{kind: KindGroup, any: attrs}}
+}
+
+// sdFlag marks KindGroup values which are structured data elements.
+const sdFlag = 1
+
+// SD returns an Attr which is sent as RFC 5424 structured data element with
+// SD-ID id and SD-PARAMs params rather than as part of the message text, e.g.
+//
+//	slog.Infow("login", slog.SD("origin", slog.String("ip", ip)))
+//
+// Parameters which are groups are flattened with dotted names. The value of
+// the Attr is a KindGroup value.
+//
+// Structured data is sent in STRUCTURED-DATA field with WithRFC5424. Other
+// formats do not support it, so structured data is sent at the beginning of
+// MSG in RFC 5424 syntax.
+func SD(id string, params ...Attr) Attr {
+	return Attr{id, Value{kind: KindGroup, num: sdFlag, any: params}}
+}
+
+// msgIDFlag marks KindString values which are message IDs.
+const msgIDFlag = 1
+
+// MsgID returns an Attr which sets RFC 5424 MSGID field of the message to id,
+// e.g. to let receivers route messages by type:
+//
+//	slog.Infow("login", slog.MsgID("LOGIN"))
+//
+// It can be attached to a logger with With too. The last one wins if a
+// message has several of them. Other formats do not have the field and
+// ignore it. The value of the Attr is a KindString value.
+func MsgID(id string) Attr {
+	return Attr{"msgid", Value{kind: KindString, num: msgIDFlag, str: id}}
+}
+
+// Any returns an Attr with an arbitrary value. Values of types supported by
+// the other constructors are stored the same way as those constructors do.
+func Any(key string, value interface{}) Attr {
+	switch v := value.(type) {
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int64:
+		return Int64(key, v)
+	case uint64:
+		return Uint64(key, v)
+	case float64:
+		return Float64(key, v)
+	case bool:
+		return Bool(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case Value:
+		return Attr{key, v}
+	}
+	return Attr{key, Value{kind: KindAny, any: value}}
+}
+
+// Kind returns the kind of v.
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+// Any returns v as interface{}.
+func (v Value) Any() interface{} {
+	switch v.kind {
+	case KindString:
+		return v.str
+	case KindInt64:
+		return int64(v.num)
+	case KindUint64:
+		return v.num
+	case KindFloat64:
+		return math.Float64frombits(v.num)
+	case KindBool:
+		return v.num == 1
+	case KindDuration:
+		return time.Duration(v.num)
+	case KindTime:
+		return v.time()
+	}
+	return v.any
+}
+
+func (v Value) isSD() bool {
+	return v.kind == KindGroup && v.num == sdFlag
+}
+
+func (v Value) isMsgID() bool {
+	return v.kind == KindString && v.num == msgIDFlag
+}
+
+// Group returns attributes of a KindGroup value or nil for other kinds.
+func (v Value) Group() []Attr {
+	attrs, _ := v.any.([]Attr)
+	return attrs
+}
+
+// String returns text representation of v, the way it appears in messages.
+func (v Value) String() string {
+	if v.kind == KindString {
+		return v.str
+	}
+	return string(v.append(nil))
+}
+
+func (v Value) time() time.Time {
+	t := time.Unix(0, int64(v.num))
+	if loc, ok := v.any.(*time.Location); ok {
+		t = t.In(loc)
+	}
+	return t
+}
+
+// append appends text representation of v to dst.
+func (v Value) append(dst []byte) []byte {
+	switch v.kind {
+	case KindString:
+		return append(dst, v.str...)
+	case KindInt64:
+		return strconv.AppendInt(dst, int64(v.num), 10)
+	case KindUint64:
+		return strconv.AppendUint(dst, v.num, 10)
+	case KindFloat64:
+		return strconv.AppendFloat(dst, math.Float64frombits(v.num), 'g', -1, 64)
+	case KindBool:
+		return strconv.AppendBool(dst, v.num == 1)
+	case KindDuration:
+		return append(dst, time.Duration(v.num).String()...)
+	case KindTime:
+		return v.time().AppendFormat(dst, time.RFC3339Nano)
+	case KindGroup:
+		var b []byte
+		for _, a := range v.Group() {
+			b = appendAttr(b, 0, "", a)
+		}
+		dst = append(dst, '[')
+		dst = append(dst, b...)
+		return append(dst, ']')
+	}
+	return fmt.Append(dst, v.any)
+}
+
+// String returns the attribute in key=value form.
+func (a Attr) String() string {
+	return a.Key + "=" + a.Value.String()
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"io"
+	"log/syslog"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// Benchmarks of sending messages to a syslog service on the local host. The
+// receiving side discards messages, so the numbers show the cost of the
+// logger and the socket write only. BenchmarkInfoDiscard leaves out the
+// socket as well.
+
+func BenchmarkInfoDiscard(b *testing.B) {
+	c, _ := net.Pipe()
+	benchmarkInfo(b, c, WithConn(discardConn{c}))
+}
+
+func BenchmarkInfoUnix(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "log")
+	c, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		b.Skip(err)
+	}
+	go discardPackets(c)
+	benchmarkInfo(b, c, WithUnixSocket(path))
+}
+
+func BenchmarkInfoUDP(b *testing.B) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Skip(err)
+	}
+	go discardPackets(c)
+	benchmarkInfo(b, c, WithDial("udp", c.LocalAddr().String()))
+}
+
+func BenchmarkInfoTCP(b *testing.B) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Skip(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, c)
+		}
+	}()
+	benchmarkInfo(b, ln, WithDial("tcp", ln.Addr().String()))
+}
+
+func BenchmarkInfoParallelUDP(b *testing.B) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Skip(err)
+	}
+	go discardPackets(c)
+	l := newBenchLogger(b, c, WithDial("udp", c.LocalAddr().String()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Info("benchmark message")
+		}
+	})
+}
+
+func BenchmarkInfofUDP(b *testing.B) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Skip(err)
+	}
+	go discardPackets(c)
+	l := newBenchLogger(b, c, WithDial("udp", c.LocalAddr().String()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Infof("benchmark message %d", i)
+	}
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Skip(err)
+	}
+	go discardPackets(c)
+	l := newBenchLogger(b, c, WithDial("udp", c.LocalAddr().String()), WithMinSeverity(syslog.LOG_INFO))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("benchmark message")
+	}
+}
+
+func benchmarkInfo(b *testing.B, c io.Closer, opts ...Option) {
+	l := newBenchLogger(b, c, opts...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("benchmark message")
+	}
+}
+
+// newBenchLogger returns a logger initialized with opts which is closed
+// together with c at the end of the benchmark.
+func newBenchLogger(b *testing.B, c io.Closer, opts ...Option) *Logger {
+	l := newLogger("")
+	if err := l.Init(opts...); err != nil {
+		c.Close()
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		l.Close()
+		c.Close()
+	})
+	return l
+}
+
+// discardPackets reads packets from c until it is closed.
+func discardPackets(c net.PacketConn) {
+	buf := make([]byte, 64<<10)
+	for {
+		if _, _, err := c.ReadFrom(buf); err != nil {
+			return
+		}
+	}
+}
+
+// discardConn is a stream connection which discards all writes.
+type discardConn struct {
+	net.Conn
+}
+
+func (discardConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
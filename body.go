@@ -0,0 +1,232 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// body is the format of MSG part of syslog messages.
+type body int
+
+const (
+	// bodyText is the message text followed by attributes in key=value
+	// form.
+	bodyText body = iota
+
+	// bodyJSON is a JSON object with the message text and attributes.
+	bodyJSON
+
+	// bodyLogfmt is the message text and attributes in logfmt.
+	bodyLogfmt
+
+	// bodyGELF is a GELF payload with the message text and attributes.
+	bodyGELF
+
+	// bodyCEF is an ArcSight Common Event Format payload.
+	bodyCEF
+
+	// bodyLEEF is an IBM QRadar Log Event Extended Format payload.
+	bodyLEEF
+)
+
+// appendLogfmtBody appends msg under "msg" key followed by attrs in logfmt,
+// i.e. space separated key=value pairs, to dst. Groups and structured data
+// elements are flattened with keys prefixed by the group name and a dot.
+func appendLogfmtBody(dst []byte, msg string, attrs []Attr) []byte {
+	start := len(dst)
+	dst = append(dst, "msg="...)
+	dst = append(dst, quoteValue(msg)...)
+	for _, a := range attrs {
+		dst = appendAttr(dst, start, "", a)
+	}
+	return dst
+}
+
+// appendJSONBody appends a JSON object with msg under "msg" key followed by
+// attrs to dst. Groups and structured data elements are nested objects.
+func appendJSONBody(dst []byte, msg string, attrs []Attr) []byte {
+	dst = append(dst, `{"msg":`...)
+	dst = appendJSONString(dst, msg)
+	for _, a := range attrs {
+		dst = append(dst, ',')
+		dst = appendJSONAttr(dst, a)
+	}
+	return append(dst, '}')
+}
+
+func appendJSONAttr(dst []byte, a Attr) []byte {
+	dst = appendJSONString(dst, a.Key)
+	dst = append(dst, ':')
+	return appendJSONValue(dst, a.Value)
+}
+
+func appendJSONValue(dst []byte, v Value) []byte {
+	switch v.kind {
+	case KindString:
+		return appendJSONString(dst, v.str)
+	case KindInt64, KindUint64, KindBool:
+		return v.append(dst)
+	case KindFloat64:
+		if f := math.Float64frombits(v.num); math.IsNaN(f) || math.IsInf(f, 0) {
+			// Not representable in JSON.
+			return appendJSONString(dst, v.String())
+		}
+		return v.append(dst)
+	case KindDuration, KindTime:
+		return appendJSONString(dst, v.String())
+	case KindGroup:
+		dst = append(dst, '{')
+		for i, a := range v.Group() {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONAttr(dst, a)
+		}
+		return append(dst, '}')
+	}
+	switch x := v.any.(type) {
+	case json.Marshaler:
+	case error:
+		return appendJSONString(dst, x.Error())
+	case fmt.Stringer:
+		return appendJSONString(dst, x.String())
+	}
+	b, err := json.Marshal(v.any)
+	if err != nil {
+		return appendJSONString(dst, v.String())
+	}
+	return append(dst, b...)
+}
+
+// gelfVersion is the version of GELF payload format.
+const gelfVersion = "1.1"
+
+var gelfHost struct {
+	once sync.Once
+	name string
+}
+
+// localHostname returns the host name for payload formats which require it.
+func localHostname() string {
+	gelfHost.once.Do(func() {
+		gelfHost.name, _ = os.Hostname()
+		if gelfHost.name == "" {
+			gelfHost.name = "localhost"
+		}
+	})
+	return gelfHost.name
+}
+
+// appendGELFBody appends GELF payload for r with attributes attrs to dst.
+// The syslog severity is used as the level. Attributes become additional
+// fields with keys prefixed by an underscore, groups and structured data
+// elements are flattened with keys joined by a dot.
+func appendGELFBody(dst []byte, p *params, r *record, attrs []Attr) []byte {
+	host := p.hostname
+	if host == "" {
+		host = localHostname()
+	}
+	dst = append(dst, `{"version":"`+gelfVersion+`","host":`...)
+	dst = appendJSONString(dst, host)
+	dst = append(dst, `,"short_message":`...)
+	dst = appendJSONString(dst, r.msg)
+	dst = append(dst, `,"timestamp":`...)
+	dst = strconv.AppendFloat(dst, float64(r.time.UnixMicro())/1e6, 'f', -1, 64)
+	dst = append(dst, `,"level":`...)
+	dst = strconv.AppendInt(dst, int64(r.severity&severityMask), 10)
+	for _, a := range attrs {
+		dst = appendGELFField(dst, "_", a)
+	}
+	return append(dst, '}')
+}
+
+// appendGELFField appends an additional field for a to dst. GELF allows only
+// strings and numbers as field values and restricts characters in names.
+func appendGELFField(dst []byte, prefix string, a Attr) []byte {
+	if a.Value.kind == KindGroup {
+		prefix += a.Key + "."
+		for _, ga := range a.Value.Group() {
+			dst = appendGELFField(dst, prefix, ga)
+		}
+		return dst
+	}
+	name := []byte(prefix + a.Key)
+	for i, c := range name {
+		if !isGELFNameChar(c) {
+			name[i] = '_'
+		}
+	}
+	if string(name) == "_id" {
+		// Reserved by Graylog.
+		name = append(name[:1], "_id"...)
+	}
+	dst = append(dst, ',')
+	dst = appendJSONString(dst, string(name))
+	dst = append(dst, ':')
+	switch a.Value.kind {
+	case KindInt64, KindUint64, KindFloat64:
+		return appendJSONValue(dst, a.Value)
+	}
+	return appendJSONString(dst, a.Value.String())
+}
+
+func isGELFNameChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '.' || c == '-'
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as JSON string to dst. Unlike encoding/json it
+// does not escape HTML characters. Invalid UTF-8 is replaced with U+FFFD.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < ' ':
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, `\ufffd`...)
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
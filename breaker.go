@@ -0,0 +1,126 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by LogE and LogfE when a message is written to
+// the default log because sending has failed repeatedly and the logger does
+// not try to send messages for a while, see WithCircuitBreaker.
+var ErrCircuitOpen = errors.New("slog: circuit breaker is open")
+
+// breakerBackend stops sending messages with b after consecutive failures,
+// see WithCircuitBreaker.
+type breakerBackend struct {
+	b           backend
+	maxFailures int
+	probe       time.Duration // Interval between probes of an open circuit.
+
+	mu        sync.Mutex
+	failures  int       // Number of consecutive failures.
+	open      bool      // Whether messages are not sent.
+	probing   bool      // Whether a message is being sent as a probe.
+	nextProbe time.Time // When an open circuit may be probed.
+}
+
+func newBreakerBackend(b backend, p *params) *breakerBackend {
+	return &breakerBackend{b: b, maxFailures: p.breakerFailures, probe: p.breakerProbe}
+}
+
+func (b *breakerBackend) send(p *params, r *record) error {
+	return b.call(p, func() error { return b.b.send(p, r) })
+}
+
+func (b *breakerBackend) sendBatch(p *params, rs []*record) error {
+	return b.call(p, func() error { return sendBatch(b.b, p, rs) })
+}
+
+// call calls send unless the circuit is open and records its result. Once
+// the probe interval passes, a single call goes through to find out whether
+// the circuit can be closed.
+func (b *breakerBackend) call(p *params, send func() error) error {
+	b.mu.Lock()
+	if b.open {
+		if b.probing || time.Now().Before(b.nextProbe) {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	b.mu.Unlock()
+
+	err := send()
+
+	b.mu.Lock()
+	wasOpen := b.open
+	b.probing = false
+	if err == nil || err == ErrRateLimited {
+		b.failures = 0
+		b.open = false
+	} else if b.failures++; b.failures >= b.maxFailures {
+		b.open = true
+		b.nextProbe = time.Now().Add(b.probe)
+	}
+	changed := b.open != wasOpen
+	open := b.open
+	b.mu.Unlock()
+	if changed && p.circuitHandler != nil {
+		p.circuitHandler(open)
+	}
+	return err
+}
+
+// isOpen reports whether the circuit is open.
+func (b *breakerBackend) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+func (b *breakerBackend) close() error {
+	return b.b.close()
+}
+
+// breaker returns the circuit breaker of w or nil if there is none.
+func (w *writer) breaker() *breakerBackend {
+	b := w.b
+	if a, ok := b.(*asyncBackend); ok {
+		b = a.b
+	}
+	cb, _ := b.(*breakerBackend)
+	return cb
+}
+
+// CircuitOpen reports whether the logger has stopped sending messages
+// because of failures. See package level CircuitOpen for details.
+func (l *Logger) CircuitOpen() bool {
+	w := l.w.Load()
+	if w == nil {
+		return false
+	}
+	cb := w.breaker()
+	return cb != nil && cb.isOpen()
+}
+
+// CircuitOpen reports whether the default logger has stopped sending
+// messages to syslog because of consecutive failures, see
+// WithCircuitBreaker, so health checks and metrics can expose it.
+func CircuitOpen() bool {
+	return std.CircuitOpen()
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// pkgPrefix is a prefix of names of functions in this package.
+var pkgPrefix = reflect.TypeOf((*Logger)(nil)).Elem().PkgPath() + "."
+
+// callerFrame returns the innermost stack frame outside of this package
+// after skipping skip more frames.
+func callerFrame(skip int) runtime.Frame {
+	var pcs [maxStackDepth]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		f, more := frames.Next()
+		if !more {
+			return f
+		}
+		if !strings.HasPrefix(f.Function, pkgPrefix) {
+			if skip == 0 {
+				return f
+			}
+			skip--
+		}
+	}
+}
+
+// caller returns the call site outside of this package in the form
+// "dir/file.go:line:function" after skipping skip more frames.
+func caller(skip int) string {
+	f := callerFrame(skip)
+	file := f.File
+	if i := strings.LastIndexByte(file, '/'); i > 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(f.Line) + ":" + f.Function
+}
+
+// callerPackage returns import path of the package calling this one.
+func callerPackage() string {
+	fn := callerFrame(0).Function
+	// Function names look like "example.com/app/storage.(*T).Method".
+	slash := strings.LastIndexByte(fn, '/') + 1
+	if dot := strings.IndexByte(fn[slash:], '.'); dot >= 0 {
+		return fn[:slash+dot]
+	}
+	return fn
+}
+
+// maxStackDepth limits the number of frames in stack traces.
+const maxStackDepth = 64
+
+// stacktrace returns the stack trace of the calling goroutine starting with
+// the innermost frame outside of this package, in the format used by panics.
+func stacktrace() string {
+	var pcs [maxStackDepth]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	var b []byte
+	for {
+		f, more := frames.Next()
+		if len(b) > 0 || !strings.HasPrefix(f.Function, pkgPrefix) {
+			b = append(b, f.Function...)
+			b = append(b, "\n\t"...)
+			b = append(b, f.File...)
+			b = append(b, ':')
+			b = strconv.AppendInt(b, int64(f.Line), 10)
+			b = append(b, '\n')
+		}
+		if !more {
+			return string(b)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"strings"
+)
+
+// device describes the product which produces messages in CEF and LEEF
+// payloads.
+type device struct {
+	vendor  string
+	product string
+	version string
+}
+
+// signatureKey is the key of an attribute used as Signature ID field of CEF
+// and EventID field of LEEF payloads. The message text is used when it is
+// absent.
+const signatureKey = "signature_id"
+
+// cefSeverities maps syslog severities to CEF severities from 0 to 10.
+var cefSeverities = [...]string{
+	syslog.LOG_EMERG:   "10",
+	syslog.LOG_ALERT:   "9",
+	syslog.LOG_CRIT:    "8",
+	syslog.LOG_ERR:     "7",
+	syslog.LOG_WARNING: "5",
+	syslog.LOG_NOTICE:  "4",
+	syslog.LOG_INFO:    "3",
+	syslog.LOG_DEBUG:   "0",
+}
+
+// appendCEFBody appends CEF payload for r with attributes attrs to dst:
+//
+//	CEF:0|Vendor|Product|Version|Signature ID|Name|Severity|Extension
+//
+// Name is the message text and Extension holds attributes in key=value form
+// with keys mapped according to p.
+func appendCEFBody(dst []byte, p *params, r *record, attrs []Attr) []byte {
+	signature := r.msg
+	for _, a := range attrs {
+		if a.Key == signatureKey && a.Value.kind != KindGroup {
+			signature = a.Value.String()
+		}
+	}
+	dst = append(dst, "CEF:0|"...)
+	for _, f := range [...]string{p.device.vendor, p.device.product, p.device.version, signature, r.msg} {
+		dst = appendCEFHeaderField(dst, f)
+		dst = append(dst, '|')
+	}
+	dst = append(dst, cefSeverities[r.severity&severityMask]...)
+	dst = append(dst, '|')
+	start := len(dst)
+	for _, a := range attrs {
+		if a.Key != signatureKey {
+			dst = appendCEFExtension(dst, start, p, "", a)
+		}
+	}
+	return dst
+}
+
+// appendCEFHeaderField appends s escaping pipes and backslashes as required
+// by CEF. Line breaks are not allowed in header fields and become spaces.
+func appendCEFHeaderField(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '|', '\\':
+			dst = append(dst, '\\', c)
+		case '\n', '\r':
+			dst = append(dst, ' ')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// appendCEFExtension appends a to dst in key=value form separated by a space
+// from the preceding extensions after start. Groups are flattened with keys
+// joined by a dot.
+func appendCEFExtension(dst []byte, start int, p *params, prefix string, a Attr) []byte {
+	if a.Value.kind == KindGroup {
+		prefix += a.Key + "."
+		for _, ga := range a.Value.Group() {
+			dst = appendCEFExtension(dst, start, p, prefix, ga)
+		}
+		return dst
+	}
+	if len(dst) > start {
+		dst = append(dst, ' ')
+	}
+	dst = append(dst, p.extensionKey(prefix+a.Key)...)
+	dst = append(dst, '=')
+	v := a.Value.String()
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '=', '\\':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// extensionKey returns the payload key for attribute key. Keys without
+// mapping are used as is with characters other than letters, digits, dots and
+// underscores replaced with underscores.
+func (p *params) extensionKey(key string) string {
+	if k, ok := p.extensionKeys[key]; ok {
+		return k
+	}
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '.' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
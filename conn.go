@@ -0,0 +1,670 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// localSyslogPaths lists UNIX sockets local syslog service may listen on.
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// connBackend sends messages to syslog service over a connection it
+// establishes itself, implementing the syslog protocol without log/syslog.
+type connBackend struct {
+	p        params
+	hostname string
+	appName  string
+	procID   string
+
+	mu       sync.Mutex // Protects conn, stream and tlsFiles.
+	conn     net.Conn   // Nil while reconnecting, see WithReconnect.
+	stream   bool       // Whether conn is a stream connection which needs framing.
+	tlsFiles []byte     // Contents of TLS files conn was established with.
+	spool    *spool     // Shared by connections of a pool, see WithSpool.
+	drop     dropFunc   // Nil when b only formats messages, see AppendMessage.
+
+	done chan struct{} // Closed when the backend is closed.
+	next atomic.Uint32 // Number of connection attempts with WithRoundRobin.
+}
+
+// newConnBackend creates a connBackend which is not connected yet. It can
+// format messages already, see AppendMessage.
+func newConnBackend(p params) *connBackend {
+	b := &connBackend{
+		p:       p,
+		appName: p.tag,
+		procID:  strconv.Itoa(os.Getpid()),
+	}
+	if b.appName == "" {
+		b.appName = filepath.Base(os.Args[0])
+	}
+	if p.network != "" || p.relayURL != nil {
+		// Local syslog service knows host name better than we do.
+		b.hostname, _ = os.Hostname()
+	}
+	return b
+}
+
+// dialConn creates a connBackend and connects it to syslog service. With
+// spool s it succeeds even if syslog service is unreachable. Messages
+// exceeding the limits set with SetRateLimit and SetByteRateLimit are
+// reported to drop.
+func dialConn(ctx context.Context, p params, s *spool, drop dropFunc) (*connBackend, error) {
+	b := newConnBackend(p)
+	b.spool = s
+	b.drop = drop
+	if g := p.given; g != nil {
+		c, err := g.conn()
+		if err != nil {
+			return nil, err
+		}
+		// Connections over UNIX sockets are treated as the ones to local
+		// syslog service.
+		if network := c.LocalAddr().Network(); !strings.HasPrefix(network, "unix") {
+			b.p.network = network
+			if addr := c.RemoteAddr(); addr != nil {
+				b.p.raddr = addr.String()
+			}
+			b.hostname, _ = os.Hostname()
+		}
+	}
+	if b.p.network == "" && b.p.relayURL == nil && p.useTLS() {
+		return nil, errors.New("TLS requires a remote syslog service, see WithDial")
+	}
+	if p.useTLS() && (p.certReloadInterval > 0 || len(p.certReloadSignals) > 0) {
+		files, err := p.tlsFiles()
+		if err != nil {
+			return nil, err
+		}
+		b.tlsFiles = files
+	}
+	if s != nil {
+		s.attach(b)
+	}
+	if err := b.connect(ctx); err != nil {
+		if s == nil {
+			return nil, err
+		}
+		if p.errorHandler != nil {
+			p.errorHandler(fmt.Errorf("connecting to syslog service: %w", err))
+		}
+	}
+	if b.tlsFiles != nil || p.reconnect || s != nil {
+		b.done = make(chan struct{})
+	}
+	if b.tlsFiles != nil {
+		go b.watchCerts()
+	}
+	if s != nil && (b.conn == nil || s.pending()) && s.claimDrain(b) {
+		go b.drain()
+	}
+	return b, nil
+}
+
+// connect establishes a new connection. It must be called with b.mu held or
+// before b is shared.
+func (b *connBackend) connect(ctx context.Context) error {
+	c, stream, err := b.open(ctx)
+	if err != nil {
+		return err
+	}
+	b.conn, b.stream = c, stream
+	return nil
+}
+
+// open establishes a new connection and reports whether it is a stream
+// connection.
+func (b *connBackend) open(ctx context.Context) (net.Conn, bool, error) {
+	if b.p.relayURL != nil {
+		// HTTP relay messages are separate requests.
+		c, err := b.openRelay()
+		return c, false, err
+	}
+	ctx, cancel := b.p.dialContext(ctx)
+	defer cancel()
+	network := b.p.network
+	var c net.Conn
+	var err error
+	switch {
+	case b.p.given != nil:
+		if c, err = b.p.given.take(b.p.givenGen); err == nil {
+			network = c.LocalAddr().Network()
+			if b.p.useTLS() {
+				c, err = b.handshake(ctx, c, b.p.raddr)
+			}
+		}
+	case network == "":
+		c, network, err = b.dialLocal(ctx)
+	default:
+		c, err = b.dialRemote(ctx)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if b.p.relp {
+		// RELP frames messages itself.
+		rc, err := relpOpen(ctx, c)
+		if err != nil {
+			return nil, false, err
+		}
+		return rc, false, nil
+	}
+	if t, ok := lookupTransport(network); ok {
+		return c, !t.Datagram, nil
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix", "pipe": // See net.Pipe.
+		return c, true, nil
+	}
+	return c, false, nil
+}
+
+// dial connects to addr on the named network with the dialer set with
+// WithDialer or with net.Dialer, through the proxy set with WithProxy if any.
+func (b *connBackend) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if u := b.p.proxyURL; u != nil {
+		c, err := b.dialDirect(ctx, network, proxyAddr(u))
+		if err != nil {
+			return nil, err
+		}
+		return proxyConnect(ctx, c, u, addr)
+	}
+	return b.dialDirect(ctx, network, addr)
+}
+
+// dialDirect connects to addr on the named network with the dialer set with
+// WithDialer, with the transport registered for network or with net.Dialer.
+func (b *connBackend) dialDirect(ctx context.Context, network, addr string) (net.Conn, error) {
+	if b.p.dialer != nil {
+		return b.p.dialer(ctx, network, addr)
+	}
+	if t, ok := lookupTransport(network); ok {
+		return t.Dial(ctx, addr)
+	}
+	d := net.Dialer{KeepAlive: b.p.keepAlive}
+	if b.p.localAddr != "" {
+		laddr, err := resolveLocalAddr(network, b.p.localAddr)
+		if err != nil {
+			return nil, err
+		}
+		if laddr != nil {
+			d.LocalAddr = laddr
+		}
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// resolveLocalAddr resolves the address given to WithLocalAddr for network.
+// Port defaults to zero so the system picks one.
+func resolveLocalAddr(network, addr string) (net.Addr, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return net.ResolveTCPAddr(network, addr)
+	case "udp", "udp4", "udp6":
+		return net.ResolveUDPAddr(network, addr)
+	}
+	// Connections to local syslog service are not bound.
+	return nil, nil
+}
+
+// dialRemote connects to one of the remote addresses, with TLS if needed.
+func (b *connBackend) dialRemote(ctx context.Context) (net.Conn, error) {
+	addrs := b.p.raddrs
+	if len(addrs) == 0 {
+		addrs = []string{b.p.raddr}
+	}
+	start := 0
+	if b.p.roundRobin {
+		start = int(b.next.Add(1)-1) % len(addrs)
+	}
+	var err error
+	for i := range addrs {
+		addr := addrs[(start+i)%len(addrs)]
+		var c net.Conn
+		if c, err = b.dial(ctx, b.p.network, addr); err != nil {
+			continue
+		}
+		if b.p.useTLS() {
+			if c, err = b.handshake(ctx, c, addr); err != nil {
+				continue
+			}
+		}
+		return c, nil
+	}
+	return nil, err
+}
+
+// dialLocal connects to local syslog service at the same locations syslog.New
+// looks for it or to the socket set with WithUnixSocket. It returns the network of the
+// connection as well.
+func (b *connBackend) dialLocal(ctx context.Context) (net.Conn, string, error) {
+	paths := localSyslogPaths
+	if b.p.unixSocket != "" {
+		paths = []string{b.p.unixSocket}
+	}
+	var err error
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range paths {
+			var c net.Conn
+			if c, err = b.dial(ctx, network, path); err == nil {
+				return c, network, nil
+			}
+		}
+	}
+	if b.p.unixSocket != "" {
+		return nil, "", err
+	}
+	return nil, "", errors.New("unix syslog delivery error")
+}
+
+// handshake secures c with TLS. It closes c if the handshake fails.
+func (b *connBackend) handshake(ctx context.Context, c net.Conn, addr string) (net.Conn, error) {
+	config, err := b.p.clientTLSConfig()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config.ServerName = host
+	}
+	tc := tls.Client(c, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// sendBuffer holds buffers for sending messages which are reused to avoid
+// allocations, see sendBuffers.
+type sendBuffer struct {
+	data   []byte    // Formatted messages one after another.
+	ends   []int     // Ends of messages in data.
+	msgs   []spooled // Messages in data.
+	parts  [][]byte  // Messages limited in size, see appendLimited.
+	frames []byte    // Framed messages to write.
+}
+
+// sendBuffers keeps sendBuffers for reuse.
+var sendBuffers = sync.Pool{New: func() interface{} { return new(sendBuffer) }}
+
+// maxPooledBuffer is the size of buffers which are not reused, so a single
+// huge message does not keep memory forever.
+const maxPooledBuffer = 64 << 10
+
+func getSendBuffer() *sendBuffer {
+	return sendBuffers.Get().(*sendBuffer)
+}
+
+// release returns buf to sendBuffers. buf must not be used afterwards.
+func (buf *sendBuffer) release() {
+	if cap(buf.data) > maxPooledBuffer || cap(buf.frames) > maxPooledBuffer {
+		return
+	}
+	for i := range buf.msgs {
+		buf.msgs[i] = spooled{}
+	}
+	for i := range buf.parts {
+		buf.parts[i] = nil
+	}
+	buf.data, buf.ends, buf.msgs = buf.data[:0], buf.ends[:0], buf.msgs[:0]
+	buf.parts, buf.frames = buf.parts[:0], buf.frames[:0]
+	sendBuffers.Put(buf)
+}
+
+func (b *connBackend) send(p *params, r *record) error {
+	rs := [1]*record{r}
+	return b.sendBatch(p, rs[:])
+}
+
+// sendBatch sends messages rs at once, with a single write over stream
+// connections. If the others are sent, it returns ErrRateLimited when some
+// messages exceed the limits.
+func (b *connBackend) sendBatch(p *params, rs []*record) (err error) {
+	buf := getSendBuffer()
+	defer buf.release()
+	limited := false
+	for _, r := range rs {
+		start := len(buf.data)
+		var body int
+		buf.data, body = b.appendFrame(buf.data, p, r)
+		if overLimit(len(buf.data) - start) {
+			buf.data = buf.data[:start]
+			b.drop(p, 1, DropRateLimited)
+			limited = true
+			continue
+		}
+		buf.ends = append(buf.ends, len(buf.data))
+		buf.msgs = append(buf.msgs, spooled{body: body - start})
+	}
+	if limited {
+		defer func() {
+			if err == nil {
+				err = ErrRateLimited
+			}
+		}()
+	}
+	if len(buf.msgs) == 0 {
+		return nil
+	}
+	start := 0
+	for i, end := range buf.ends {
+		// Appending to a message must not overwrite the next one.
+		buf.msgs[i].msg = buf.data[start:end:end]
+		start = end
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spool != nil && (b.conn == nil || b.spool.isDraining()) {
+		// Keep the order of messages.
+		return b.spoolMessages(buf.msgs)
+	}
+	if b.conn == nil {
+		return errReconnecting
+	}
+	for _, m := range buf.msgs {
+		buf.parts = b.appendLimited(buf.parts, p, m.msg, m.body)
+	}
+	err = b.write(buf)
+	if err != nil && brokenConn(err) {
+		// Most likely syslog service has been restarted, so try once more
+		// over a new connection.
+		if c, stream, derr := b.open(context.Background()); derr == nil {
+			b.conn.Close()
+			b.conn, b.stream = c, stream
+			err = b.write(buf)
+		}
+	}
+	if err != nil && b.spool != nil {
+		b.conn.Close()
+		b.conn = nil
+		return b.spoolMessages(buf.msgs)
+	}
+	if err != nil && b.p.reconnect {
+		b.conn.Close()
+		b.conn = nil
+		go b.reconnect()
+	}
+	return err
+}
+
+// spoolMessages saves msgs in the spool and makes sure they are replayed. It
+// must be called with b.mu held.
+func (b *connBackend) spoolMessages(msgs []spooled) error {
+	if err := b.spool.append(msgs); err != nil {
+		return err
+	}
+	if b.spool.claimDrain(b) {
+		go b.drain()
+	}
+	return nil
+}
+
+// drain replays spooled messages restoring the connection according to the
+// retry policy of b.p until the spool is empty or b is closed.
+func (b *connBackend) drain() {
+	delay := b.p.reconnectPolicy.first()
+	for {
+		err := b.replay()
+		if err == nil {
+			return
+		}
+		if h := b.p.latest().errorHandler; h != nil {
+			h(fmt.Errorf("replaying spooled messages: %w", err))
+		}
+		t := time.NewTimer(b.p.reconnectPolicy.jitter(delay))
+		select {
+		case <-b.done:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		delay = b.p.reconnectPolicy.next(delay)
+	}
+}
+
+// replay connects to syslog service if needed and sends spooled messages. It
+// returns nil when the spool is empty or b is closed.
+func (b *connBackend) replay() error {
+	b.mu.Lock()
+	connected := b.conn != nil
+	b.mu.Unlock()
+	if !connected {
+		c, stream, err := b.open(context.Background())
+		if err != nil {
+			return err
+		}
+		b.mu.Lock()
+		select {
+		case <-b.done:
+			c.Close()
+		default:
+			b.conn, b.stream = c, stream
+		}
+		b.mu.Unlock()
+	}
+	for {
+		if empty, err := b.replayBatch(); empty || err != nil {
+			return err
+		}
+	}
+}
+
+// replayBatch sends up to spoolBatchSize spooled messages and reports whether
+// replaying is over.
+func (b *connBackend) replayBatch() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.done:
+		return true, nil
+	default:
+	}
+	if b.conn == nil {
+		return false, errReconnecting
+	}
+	buf := getSendBuffer()
+	defer buf.release()
+	var werr error
+	empty, err := b.spool.replay(spoolBatchSize, func(ms []spooled) error {
+		buf.parts = buf.parts[:0]
+		for _, m := range ms {
+			buf.parts = b.appendLimited(buf.parts, &b.p, m.msg, m.body)
+		}
+		werr = b.write(buf)
+		return werr
+	})
+	if werr != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return empty, nil
+}
+
+// write writes buf.parts to the current connection framing them as needed.
+// It must be called with b.mu held.
+func (b *connBackend) write(buf *sendBuffer) error {
+	frames := buf.frames[:0]
+	defer func() { buf.frames = frames }()
+	switch {
+	case b.stream:
+		for _, m := range buf.parts {
+			frames = b.appendFramed(frames, m)
+		}
+	case b.p.relayURL != nil:
+		// HTTP relay receives batches as lines.
+		for i, m := range buf.parts {
+			if i > 0 {
+				frames = append(frames, '\n')
+			}
+			frames = b.appendFramed(frames, m)
+		}
+	default:
+		for _, m := range buf.parts {
+			frames = b.appendFramed(frames[:0], m)
+			if _, err := b.conn.Write(frames); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := b.conn.Write(frames)
+	return err
+}
+
+// appendLimited applies the size limits to message msg with MSG part starting
+// at body and appends the resulting messages to dst. It must be called with
+// b.mu held.
+func (b *connBackend) appendLimited(dst [][]byte, p *params, msg []byte, body int) [][]byte {
+	limit := p.maxMessageSize
+	truncate := false
+	if p.format == formatRFC3164 && (limit == 0 || limit > maxRFC3164Len) {
+		limit = maxRFC3164Len
+		truncate = p.maxMessageSize == 0 && !p.splitMessages
+	}
+	if b.datagram() {
+		n := p.datagramLimit(b.p.network)
+		if n > 0 && b.p.stdlibFormat() {
+			n-- // Leave space for the line break.
+		}
+		if n > 0 && (limit == 0 || limit > n) {
+			limit = n
+			truncate = false
+		}
+	}
+	switch {
+	case limit == 0 || len(msg) <= limit:
+		return append(dst, msg)
+	case truncate:
+		return append(dst, truncateUTF8(msg, limit))
+	}
+	return append(dst, limitMessage(msg, body, limit, p.splitMessages)...)
+}
+
+// datagram reports whether the current connection is a datagram connection.
+// RELP and HTTP relay connections are not streams but they are not limited
+// in size either. It must be called with b.mu held.
+func (b *connBackend) datagram() bool {
+	return !b.stream && !b.p.relp && b.p.relayURL == nil
+}
+
+// appendFramed appends msg framed for sending over the current connection to
+// dst. It must be called with b.mu held.
+func (b *connBackend) appendFramed(dst, msg []byte) []byte {
+	switch {
+	case b.stream && b.p.octetCounting():
+		dst = strconv.AppendInt(dst, int64(len(msg)), 10)
+		dst = append(dst, ' ')
+		return append(dst, msg...)
+	case b.stream && b.p.delimiter != "":
+		return append(append(dst, msg...), b.p.delimiter...)
+	case b.p.stdlibFormat() && (b.stream || b.datagram()):
+		// syslog.Writer ends all messages with a line break.
+		dst = append(dst, msg...)
+		if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+			return dst
+		}
+		return append(dst, '\n')
+	case !b.stream:
+		return append(dst, msg...)
+	}
+	return append(append(dst, msg...), '\n')
+}
+
+// brokenConn reports whether err means that the connection has been closed
+// by the other side or cannot be used any more.
+func brokenConn(err error) bool {
+	var rb *relpBroken
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.EOF) ||
+		errors.As(err, &rb)
+}
+
+// errReconnecting is returned for messages sent while the connection is
+// being restored, see WithReconnect.
+var errReconnecting = errors.New("slog: reconnecting to syslog service")
+
+// reconnect restores the connection according to the retry policy of b.p
+// until it succeeds or b is closed.
+func (b *connBackend) reconnect() {
+	delay := b.p.reconnectPolicy.first()
+	for {
+		t := time.NewTimer(b.p.reconnectPolicy.jitter(delay))
+		select {
+		case <-b.done:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		c, stream, err := b.open(context.Background())
+		if err == nil {
+			b.mu.Lock()
+			select {
+			case <-b.done:
+				c.Close()
+			default:
+				b.conn, b.stream = c, stream
+			}
+			b.mu.Unlock()
+			return
+		}
+		if h := b.p.latest().errorHandler; h != nil {
+			h(fmt.Errorf("reconnecting to syslog service: %w", err))
+		}
+		delay = b.p.reconnectPolicy.next(delay)
+	}
+}
+
+func (b *connBackend) close() error {
+	if b.done != nil {
+		close(b.done)
+	}
+	if b.spool != nil {
+		b.spool.detach(b)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	if g := b.p.given; g != nil && !g.owned(b.p.givenGen) {
+		return nil
+	}
+	return b.conn.Close()
+}
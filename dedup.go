@@ -0,0 +1,163 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"sync"
+	"time"
+)
+
+// dedupKeyFlag marks KindString values which are deduplication keys.
+const dedupKeyFlag = 2
+
+// DedupKey returns an Attr which marks messages which are the same for the
+// purpose of deduplication, see WithDedupWindow, e.g. messages about a failed
+// request with varying details:
+//
+//	slog.Warningw("request failed", slog.DedupKey("upstream"), "error", err)
+//
+// It can be attached to a logger with With too. The Attr itself is not sent.
+func DedupKey(key string) Attr {
+	return Attr{"dedup", Value{kind: KindString, num: dedupKeyFlag, str: key}}
+}
+
+func (v Value) isDedupKey() bool {
+	return v.kind == KindString && v.num == dedupKeyFlag
+}
+
+// dedupEntry is a message sent within the current window of its key.
+type dedupEntry struct {
+	l        *Logger // The one which sent the message.
+	severity syslog.Priority
+	msg      string
+	attrs    []Attr
+	n        int // Number of suppressed messages.
+}
+
+// dedup keeps messages sent within their windows, see WithDedupWindow.
+type dedup struct {
+	window time.Duration
+	field  string
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+func newDedup(window time.Duration, field string) *dedup {
+	return &dedup{window: window, field: field, entries: make(map[string]*dedupEntry)}
+}
+
+// key returns the deduplication key of a message with attrs sent by l, see
+// dedupKey.
+func (d *dedup) key(l *Logger, attrs []Attr) (string, bool) {
+	return dedupKey(l, attrs, d.field)
+}
+
+// dedupKey returns the deduplication key of a message with attrs sent by l,
+// if any, taken from a DedupKey attribute or an attribute with key field
+// unless it is empty. The last key wins.
+func dedupKey(l *Logger, attrs []Attr, field string) (string, bool) {
+	key, ok := "", false
+	for _, as := range [2][]Attr{l.fields, attrs} {
+		for _, a := range as {
+			switch {
+			case a.Value.isDedupKey():
+				key, ok = a.Value.str, true
+			case field != "" && a.Key == field && a.Value.kind != KindGroup:
+				key, ok = a.Value.String(), true
+			}
+		}
+	}
+	return key, ok
+}
+
+// check reports whether the message has to be suppressed because another one
+// with the same key has been sent within the window. Otherwise it starts a
+// new window for the key.
+func (d *dedup) check(l *Logger, key string, severity syslog.Priority, msg string, attrs []Attr) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e := d.entries[key]; e != nil {
+		e.n++
+		return true
+	}
+	// Keep a copy of attributes in case the caller reuses the slice.
+	e := &dedupEntry{l: l, severity: severity, msg: msg, attrs: append([]Attr(nil), attrs...)}
+	d.entries[key] = e
+	time.AfterFunc(d.window, func() { d.expire(key, e) })
+	return false
+}
+
+// expire ends the window of entry e with the given key and sends the number
+// of suppressed messages, if any.
+func (d *dedup) expire(key string, e *dedupEntry) {
+	d.mu.Lock()
+	if d.entries[key] != e {
+		// Flushed already.
+		d.mu.Unlock()
+		return
+	}
+	delete(d.entries, key)
+	d.mu.Unlock()
+	e.send()
+}
+
+// flush ends all windows and sends the numbers of suppressed messages.
+func (d *dedup) flush() {
+	d.mu.Lock()
+	entries := d.entries
+	d.entries = make(map[string]*dedupEntry)
+	d.mu.Unlock()
+	for _, e := range entries {
+		e.send()
+	}
+}
+
+// send sends a copy of the message of e with the number of suppressed
+// messages unless there are none.
+func (e *dedupEntry) send() {
+	if e.n == 0 {
+		return
+	}
+	attrs := append(e.attrs, Int("repeated", e.n))
+	e.l.emit(e.l.w.Load(), e.severity, e.msg, attrs, false)
+}
+
+// setDedup makes the logger deduplicate messages within window, keeping the
+// current windows if the settings have not changed.
+func (l *Logger) setDedup(window time.Duration, field string) {
+	if d := l.dedup.Load(); d != nil && d.window == window && d.field == field {
+		return
+	}
+	var d *dedup
+	if window > 0 {
+		d = newDedup(window, field)
+	}
+	if old := l.dedup.Swap(d); old != nil {
+		old.flush()
+	}
+}
+
+// deduplicated reports whether the message has to be suppressed according to
+// WithDedupWindow.
+func (l *Logger) deduplicated(severity syslog.Priority, msg string, attrs []Attr) bool {
+	d := l.dedup.Load()
+	if d == nil {
+		return false
+	}
+	key, ok := d.key(l, attrs)
+	return ok && d.check(l, key, severity, msg, attrs)
+}
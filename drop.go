@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import "strconv"
+
+// DropReason tells why a message has not been sent to syslog, see
+// WithDropHandler.
+type DropReason int
+
+const (
+	// DropOverflow means the queue of WithAsync was full.
+	DropOverflow DropReason = iota
+
+	// DropInvalid means the message failed validation, see WithDropInvalid.
+	DropInvalid
+
+	// DropSendFailed means sending the message failed, so it has been
+	// written to the default log instead.
+	DropSendFailed
+
+	// DropPurged means the message has been removed from the spool before
+	// it could be replayed, see WithSpoolLimits.
+	DropPurged
+
+	// DropSampled means the message has been discarded by sampling, see
+	// WithSampling.
+	DropSampled
+
+	// DropRateLimited means the message has exceeded a rate limit, see
+	// SetTagRateLimit and SetRateLimit.
+	DropRateLimited
+
+	numDropReasons
+)
+
+var dropReasonNames = [numDropReasons]string{
+	DropOverflow:    "overflow",
+	DropInvalid:     "invalid",
+	DropSendFailed:  "send failed",
+	DropPurged:      "purged",
+	DropSampled:     "sampled",
+	DropRateLimited: "rate limited",
+}
+
+// String returns a short description of the reason, e.g. "overflow".
+func (r DropReason) String() string {
+	if r < 0 || r >= numDropReasons {
+		return "DropReason(" + strconv.Itoa(int(r)) + ")"
+	}
+	return dropReasonNames[r]
+}
+
+// dropFunc records that n messages sent according to p have been dropped.
+type dropFunc func(p *params, n int, reason DropReason)
+
+// drop records that n messages have been dropped and notifies the handler
+// set with WithDropHandler.
+func (l *Logger) drop(p *params, n int, reason DropReason) {
+	l.dropped[reason].Add(uint64(n))
+	if p != nil && p.dropHandler != nil {
+		p.dropHandler(n, reason)
+	}
+}
+
+// purgeFunc records that n messages of the given total size sent according
+// to p have been purged from the spool.
+type purgeFunc func(p *params, n int, bytes int64)
+
+// purge records that n messages have been purged from the spool, see
+// WithSpoolLimits.
+func (l *Logger) purge(p *params, n int, bytes int64) {
+	l.purgedBytes.Add(uint64(bytes))
+	l.drop(p, n, DropPurged)
+}
+
+// Dropped returns the number of messages of the logger which have not been
+// sent to syslog for reason since the program started. See package level
+// Dropped for details.
+func (l *Logger) Dropped(reason DropReason) uint64 {
+	if reason < 0 || reason >= numDropReasons {
+		return 0
+	}
+	return l.dropped[reason].Load()
+}
+
+// Dropped returns the number of messages of the default logger which have
+// not been sent to syslog for reason since the program started, so operators
+// can see that messages have been lost and why. Messages logged before Init
+// are not counted.
+func Dropped(reason DropReason) uint64 {
+	return std.Dropped(reason)
+}
+
+// PurgedBytes returns the number of bytes of messages of the logger purged
+// from the spool since the program started. See package level PurgedBytes
+// for details.
+func (l *Logger) PurgedBytes() uint64 {
+	return l.purgedBytes.Load()
+}
+
+// PurgedBytes returns the number of bytes of messages of the default logger
+// purged from the spool because of the limits set with WithSpoolLimits since
+// the program started. The number of purged messages is returned by Dropped
+// for DropPurged.
+func PurgedBytes() uint64 {
+	return std.PurgedBytes()
+}
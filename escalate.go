@@ -0,0 +1,148 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"sync"
+	"time"
+)
+
+// EscalationRule raises severity of messages which occur too often, see
+// WithEscalation.
+type EscalationRule struct {
+	// Severity is the severity of messages the rule applies to.
+	Severity syslog.Priority
+
+	// Messages are escalated once more than Count of them with the same
+	// key have been sent within Window.
+	Count  int
+	Window time.Duration
+
+	// To is the severity of escalated messages.
+	To syslog.Priority
+}
+
+// maxEscalationCounts is the number of counts kept by escalator above which
+// expired ones are removed.
+const maxEscalationCounts = 1024
+
+// escalator applies rules of WithEscalation.
+type escalator struct {
+	rules []EscalationRule
+
+	mu     sync.Mutex
+	counts map[escalationKey]*escalationCount
+}
+
+type escalationKey struct {
+	rule int
+	key  string
+}
+
+// escalationCount counts messages in the window started at start.
+type escalationCount struct {
+	start time.Time
+	n     int
+}
+
+func newEscalator(rules []EscalationRule) *escalator {
+	return &escalator{rules: rules, counts: make(map[escalationKey]*escalationCount)}
+}
+
+// escalate counts a message with severity and key and returns its severity
+// after applying the rules.
+func (e *escalator) escalate(severity syslog.Priority, key string) syslog.Priority {
+	for i, rule := range e.rules {
+		if rule.Severity != severity {
+			continue
+		}
+		if e.count(i, key) > rule.Count {
+			return rule.To
+		}
+	}
+	return severity
+}
+
+// count counts a message with key for rule i and returns the number of them
+// in the current window.
+func (e *escalator) count(i int, key string) int {
+	now := time.Now()
+	k := escalationKey{i, key}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.counts[k]
+	if c == nil {
+		if len(e.counts) >= maxEscalationCounts {
+			e.removeExpired(now)
+		}
+		c = &escalationCount{start: now}
+		e.counts[k] = c
+	}
+	if now.Sub(c.start) >= e.rules[i].Window {
+		c.start, c.n = now, 0
+	}
+	c.n++
+	return c.n
+}
+
+// removeExpired removes counts with windows ended before now. It must be
+// called with e.mu held.
+func (e *escalator) removeExpired(now time.Time) {
+	for k, c := range e.counts {
+		if now.Sub(c.start) >= e.rules[k.rule].Window {
+			delete(e.counts, k)
+		}
+	}
+}
+
+// setEscalation makes the logger escalate messages according to rules,
+// keeping the current counts if the rules have not changed.
+func (l *Logger) setEscalation(rules []EscalationRule) {
+	if len(rules) == 0 {
+		l.escalator.Store(nil)
+		return
+	}
+	if e := l.escalator.Load(); e != nil && sameRules(e.rules, rules) {
+		return
+	}
+	l.escalator.Store(newEscalator(rules))
+}
+
+func sameRules(a, b []EscalationRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// escalate returns severity of the message after applying the rules of
+// WithEscalation.
+func (l *Logger) escalate(severity syslog.Priority, msg string, attrs []Attr) syslog.Priority {
+	e := l.escalator.Load()
+	if e == nil {
+		return severity
+	}
+	key, ok := dedupKey(l, attrs, "")
+	if !ok {
+		key = msg
+	}
+	return e.escalate(severity, key)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"sync/atomic"
+)
+
+// FilterID identifies a filter added with AddFilter.
+type FilterID uint64
+
+type filter struct {
+	id   FilterID
+	keep func(severity syslog.Priority, message string) bool
+}
+
+var lastFilterID atomic.Uint64
+
+// AddFilter adds a filter to the default logger. See Logger.AddFilter.
+func AddFilter(keep func(severity syslog.Priority, message string) bool) FilterID {
+	return std.AddFilter(keep)
+}
+
+// RemoveFilter removes a filter added with AddFilter from the default logger.
+func RemoveFilter(id FilterID) {
+	std.RemoveFilter(id)
+}
+
+// AddFilter adds a filter which is called for every message sent by the
+// logger with severity enabled. If keep returns false, the message is
+// discarded. Filters are called in the order they were added and may be
+// called from concurrent goroutines. Unlike options of Init, filters can be
+// added and removed at any time and are kept across re-initialization.
+func (l *Logger) AddFilter(keep func(severity syslog.Priority, message string) bool) FilterID {
+	id := FilterID(lastFilterID.Add(1))
+	l.updateFilters(func(fs []filter) []filter {
+		return append(fs, filter{id, keep})
+	})
+	return id
+}
+
+// RemoveFilter removes a filter added with AddFilter. Removing a filter which
+// does not exist is a no-op.
+func (l *Logger) RemoveFilter(id FilterID) {
+	l.updateFilters(func(fs []filter) []filter {
+		for i, f := range fs {
+			if f.id == id {
+				return append(fs[:i], fs[i+1:]...)
+			}
+		}
+		return fs
+	})
+}
+
+// updateFilters replaces filters of the logger with a copy modified by f.
+func (l *Logger) updateFilters(f func([]filter) []filter) {
+	l.filtersMu.Lock()
+	defer l.filtersMu.Unlock()
+	var fs []filter
+	if p := l.filters.Load(); p != nil {
+		fs = append(fs, *p...)
+	}
+	fs = f(fs)
+	l.filters.Store(&fs)
+}
+
+// filtered reports whether any of the filters discards the message.
+func (l *Logger) filtered(severity syslog.Priority, message string) bool {
+	p := l.filters.Load()
+	if p == nil {
+		return false
+	}
+	for _, f := range *p {
+		if !f.keep(severity, message) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,330 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// format is the format of syslog messages.
+type format int
+
+const (
+	// formatStdlib is the format produced by syslog.Writer of the standard
+	// library.
+	formatStdlib format = iota
+
+	// formatRFC5424 is the format described in RFC 5424.
+	formatRFC5424
+
+	// formatRFC3164 is the format described in RFC 3164.
+	formatRFC3164
+)
+
+// rfc5424Time is the format of RFC 5424 TIMESTAMP with microseconds.
+const rfc5424Time = "2006-01-02T15:04:05.000000Z07:00"
+
+// Maximum lengths of RFC 5424 header fields.
+const (
+	maxHostnameLen = 255
+	maxAppNameLen  = 48
+	maxProcIDLen   = 128
+	maxMsgIDLen    = 32
+)
+
+// RFC 3164 limits.
+const (
+	maxRFC3164Len    = 1024
+	maxRFC3164TagLen = 32
+)
+
+// Default limits of datagram size, see WithMaxDatagramSize.
+const (
+	// defaultUDPSize fits in a single Ethernet frame with IPv4 and UDP
+	// headers, so datagrams are not fragmented.
+	defaultUDPSize = 1472
+	// defaultUnixgramSize is the default limit of local syslog services
+	// like rsyslog.
+	defaultUnixgramSize = 8192
+)
+
+// datagramLimit returns the maximum size of datagrams sent over network, or
+// zero if it is not limited.
+func (p *params) datagramLimit(network string) int {
+	switch {
+	case p.datagramSize < 0:
+		return 0
+	case p.datagramSize > 0:
+		return p.datagramSize
+	case network == "":
+		return defaultUnixgramSize
+	}
+	return defaultUDPSize
+}
+
+// appendFrame appends r formatted according to p to dst. It returns the
+// offset of MSG part in the frame as well. Messages formatted by the caller
+// are taken as is and they are considered to have no MSG part, so they are
+// truncated rather than split when they are too long.
+func (b *connBackend) appendFrame(dst []byte, p *params, r *record) ([]byte, int) {
+	switch {
+	case r.raw != nil:
+		dst = append(dst, r.raw...)
+		return dst, len(dst)
+	case p.format == formatRFC3164:
+		return b.appendRFC3164(dst, p, r)
+	case p.stdlibFormat():
+		return b.appendStdlib(dst, p, r)
+	}
+	return b.appendRFC5424(dst, p, r)
+}
+
+// appendStdlib appends r formatted the way syslog.Writer of the standard
+// library does it to dst, except for the trailing line break added by
+// appendFramed. The timestamp is in RFC 3339 format and is followed by host
+// name for remote syslog service, and it is in "Jan _2 15:04:05" format
+// without host name for the local one.
+func (b *connBackend) appendStdlib(dst []byte, p *params, r *record) ([]byte, int) {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(p.facility|r.severity), 10)
+	dst = append(dst, '>')
+	if !p.noTimestamp {
+		if b.p.network == "" {
+			dst = p.time(r).AppendFormat(dst, time.Stamp)
+		} else {
+			dst = p.time(r).AppendFormat(dst, time.RFC3339)
+		}
+		dst = append(dst, ' ')
+	}
+	if b.p.network != "" {
+		dst = append(dst, b.hostnameFor(p)...)
+		dst = append(dst, ' ')
+	}
+	tag := b.appNameFor(p)
+	if p.appName == "" && b.p.tag == "" {
+		// syslog.Writer uses the full path of the program.
+		tag = os.Args[0]
+	}
+	dst = append(dst, tag...)
+	dst = append(dst, '[')
+	dst = append(dst, b.procID...)
+	dst = append(dst, "]: "...)
+	body := len(dst)
+	return appendBody(dst, p, r, r.attrs), body
+}
+
+// appendRFC5424 appends r formatted according to RFC 5424 to dst. Groups of
+// attributes are sent as structured data elements with SD-IDs equal to the
+// group keys.
+func (b *connBackend) appendRFC5424(dst []byte, p *params, r *record) ([]byte, int) {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(p.facility|r.severity), 10)
+	dst = append(dst, ">1 "...)
+	if p.noTimestamp {
+		dst = append(dst, '-')
+	} else {
+		dst = p.time(r).AppendFormat(dst, p.timeLayout)
+	}
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, b.hostnameFor(p), maxHostnameLen)
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, b.appNameFor(p), maxAppNameLen)
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, b.procID, maxProcIDLen)
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, r.msgID, maxMsgIDLen)
+	dst = append(dst, ' ')
+
+	// Text body cannot hold groups well, so they go to STRUCTURED-DATA too.
+	var sd, attrs []Attr
+	for _, a := range r.attrs {
+		if a.Value.isSD() || a.Value.kind == KindGroup && p.body == bodyText {
+			sd = append(sd, a)
+		} else {
+			attrs = append(attrs, a)
+		}
+	}
+	if len(sd) == 0 {
+		dst = append(dst, '-')
+	} else {
+		dst = appendSD(dst, sd)
+	}
+	if len(r.msg) == 0 && len(attrs) == 0 && p.body == bodyText {
+		return dst, len(dst)
+	}
+	dst = append(dst, ' ')
+	if p.bom {
+		dst = append(dst, bom...)
+	}
+	body := len(dst)
+	return appendBody(dst, p, r, attrs), body
+}
+
+// hostnameFor returns HOSTNAME field for messages formatted according to p.
+func (b *connBackend) hostnameFor(p *params) string {
+	if p.hostname != "" {
+		return p.hostname
+	}
+	return b.hostname
+}
+
+// appNameFor returns APP-NAME field for messages formatted according to p.
+func (b *connBackend) appNameFor(p *params) string {
+	if p.appName != "" {
+		return p.appName
+	}
+	return b.appName
+}
+
+// time returns the timestamp of r in the location selected by p.
+func (p *params) time(r *record) time.Time {
+	if p.timeLocation != nil {
+		return r.time.In(p.timeLocation)
+	}
+	return r.time.Local()
+}
+
+// appendHeaderField appends value of RFC 5424 header field to dst. Empty
+// values are replaced with NILVALUE, characters other than printable
+// US-ASCII are replaced with '_' and the value is truncated to limit bytes.
+func appendHeaderField(dst []byte, value string, limit int) []byte {
+	if value == "" {
+		return append(dst, '-')
+	}
+	if len(value) > limit {
+		value = value[:limit]
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c >= 0x7f {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// appendRFC3164 appends r formatted strictly according to RFC 3164 to dst:
+// the timestamp is in local time without year and TAG contains only
+// alphanumeric characters. Host name is omitted for local syslog service as
+// it is customary. The caller is responsible for limiting the message to 1024
+// bytes.
+func (b *connBackend) appendRFC3164(dst []byte, p *params, r *record) ([]byte, int) {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(p.facility|r.severity), 10)
+	dst = append(dst, '>')
+	if !p.noTimestamp {
+		dst = p.time(r).AppendFormat(dst, time.Stamp)
+		dst = append(dst, ' ')
+		if hostname := b.hostnameFor(p); hostname != "" {
+			dst = appendHeaderField(dst, hostname, maxHostnameLen)
+			dst = append(dst, ' ')
+		}
+	}
+	tagStart := len(dst)
+	appName := b.appNameFor(p)
+	for i := 0; i < len(appName) && len(dst)-tagStart < maxRFC3164TagLen; i++ {
+		if c := appName[i]; 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			dst = append(dst, c)
+		}
+	}
+	dst = append(dst, '[')
+	dst = append(dst, b.procID...)
+	dst = append(dst, "]: "...)
+	body := len(dst)
+	return appendBody(dst, p, r, r.attrs), body
+}
+
+// limitMessage returns msg limited to limit bytes. The MSG part starting at
+// body is either truncated, see truncateMessage, or split into several
+// messages with the preceding header repeated in each of them and the part
+// number inserted after it, e.g. "[part 2/5] ". If the header leaves no space
+// for MSG part, the message is truncated anyway.
+func limitMessage(msg []byte, body, limit int, split bool) [][]byte {
+	if !split {
+		return [][]byte{truncateMessage(msg, limit)}
+	}
+	header, rest := msg[:body], msg[body:]
+	var parts [][]byte
+	// The marker length depends on the number of parts, so repeat with more
+	// digits until it fits.
+	for digits := 1; ; digits++ {
+		space := limit - len(header) - len("[part /] ") - 2*digits
+		if space < utf8.UTFMax {
+			return [][]byte{truncateMessage(msg, limit)}
+		}
+		parts = parts[:0]
+		for s := rest; len(s) > 0; {
+			part := truncateUTF8(s, space)
+			parts = append(parts, part)
+			s = s[len(part):]
+		}
+		if len(strconv.Itoa(len(parts))) <= digits {
+			break
+		}
+	}
+	msgs := make([][]byte, len(parts))
+	total := strconv.Itoa(len(parts))
+	for i, part := range parts {
+		m := append([]byte(nil), header...)
+		m = append(m, "[part "...)
+		m = strconv.AppendInt(m, int64(i+1), 10)
+		m = append(m, '/')
+		m = append(m, total...)
+		m = append(m, "] "...)
+		msgs[i] = append(m, part...)
+	}
+	return msgs
+}
+
+// truncateMessage truncates b to at most limit bytes without splitting a
+// UTF-8 encoded character and appends a marker with the number of bytes cut
+// off, if there is space for it.
+func truncateMessage(b []byte, limit int) []byte {
+	if len(b) <= limit {
+		return b
+	}
+	// The marker length depends on the number of bytes cut off which depends
+	// on the marker length, so repeat until they agree.
+	var marker string
+	cut := len(b) - limit
+	for i := 0; i < 3; i++ {
+		marker = "...[truncated " + strconv.Itoa(cut) + " bytes]"
+		if len(marker) > limit {
+			return truncateUTF8(b, limit)
+		}
+		n := len(truncateUTF8(b, limit-len(marker)))
+		if len(b)-n == cut {
+			break
+		}
+		cut = len(b) - n
+	}
+	return append(truncateUTF8(b, len(b)-cut), marker...)
+}
+
+// truncateUTF8 truncates b to at most n bytes without splitting a UTF-8
+// encoded character.
+func truncateUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return b[:n]
+}
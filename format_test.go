@@ -0,0 +1,84 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"log/syslog"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportKeepsFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string // Regular expression matching the frame.
+	}{
+		{"default", nil, `^<14>\S+ \S+ x\[\d+\]: hello\n$`},
+		{"octet counting", []Option{WithOctetCounting()}, `^\d+ <14>\S+ \S+ x\[\d+\]: hello$`},
+		{"delimiter", []Option{WithFrameDelimiter("\x00")}, `^<14>\S+ \S+ x\[\d+\]: hello\x00$`},
+		{"reconnect", []Option{WithReconnect(RetryPolicy{})}, `^<14>\S+ \S+ x\[\d+\]: hello\n$`},
+		{"RFC 5424", []Option{WithRFC5424(), WithOctetCounting()}, `^\d+ <14>1 \S+ \S+ x \d+ - - hello$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			frames := make(chan string, 1)
+			go func() {
+				buf := make([]byte, 1024)
+				n, _ := bufio.NewReader(server).Read(buf)
+				frames <- string(buf[:n])
+			}()
+			l := newLogger("")
+			defer l.Close()
+			if err := l.Init(append([]Option{WithConn(client), WithTag("x"), WithFacility(syslog.LOG_USER)}, tt.opts...)...); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.LogE(syslog.LOG_INFO, "hello"); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case frame := <-frames:
+				if !regexp.MustCompile(tt.want).MatchString(frame) {
+					t.Errorf("sent %q, want it to match %q", frame, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no message received")
+			}
+		})
+	}
+}
+
+func TestEscapeControlKeepsSeparators(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	r := readPipe(server)
+	l := newLogger("")
+	defer l.Close()
+	if err := l.Init(WithConn(client), WithLEEF("v", "p", "1"), WithEscapedControlChars()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.With(String("k", "x\ny")).LogE(syslog.LOG_INFO, "a\tb"); err != nil {
+		t.Fatal(err)
+	}
+	if line, want := r.next(t), "\tmsg=a\\tb\tk=x\\ny"; !strings.HasSuffix(line, want) {
+		t.Errorf("sent %q, want it to end with %q", line, want)
+	}
+}
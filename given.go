@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// errGivenConnClosed is returned when a connection given with WithConn or
+// WithFile is needed again after it has been closed.
+var errGivenConnClosed = errors.New("slog: connection given to the logger has been closed")
+
+// lastGivenGen numbers the options applied to use a given connection, so a
+// WithConn or WithFile option used again for re-initialization takes the
+// connection over from the previous backend, see givenConn.take.
+var lastGivenGen atomic.Uint64
+
+// givenConn is a connection established outside of the package, see WithConn
+// and WithFile.
+type givenConn struct {
+	c net.Conn
+	f *os.File // Converted to c on first use.
+
+	once sync.Once
+	err  error
+
+	mu    sync.Mutex
+	taken uint64 // Generation which took the connection last.
+	owner uint64 // Generation allowed to close the connection.
+
+	// Set when a logger re-initialized with the same connection has taken
+	// it over, so closing the old backend must leave it open.
+	handedOver atomic.Bool
+}
+
+// conn returns the connection converting the file to it if needed.
+func (g *givenConn) conn() (net.Conn, error) {
+	g.once.Do(func() {
+		if g.f != nil {
+			g.c, g.err = net.FileConn(g.f)
+		}
+	})
+	return g.c, g.err
+}
+
+// take returns the connection for the first connection attempt of a backend
+// created with generation gen only, as it cannot be established again. A
+// backend of an older generation than the last one to take the connection
+// does not get it either, as it has been handed over.
+func (g *givenConn) take(gen uint64) (net.Conn, error) {
+	c, err := g.conn()
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if gen <= g.taken {
+		return nil, errGivenConnClosed
+	}
+	g.taken = gen
+	if gen > g.owner {
+		g.owner = gen
+	}
+	return c, nil
+}
+
+// sameGivenConn reports whether p and q use the same given connection.
+func (p *params) sameGivenConn(q *params) bool {
+	if p.given == nil || q.given == nil {
+		return p.given == q.given
+	}
+	if p.given.f != nil || q.given.f != nil {
+		return p.given.f == q.given.f
+	}
+	return p.given.c == q.given.c
+}
+
+// handOver marks the connection given in p as taken over by a writer created
+// with q if both use the same one, see givenConn.owned. This includes the
+// case of the same option given to both initializations.
+func (p *params) handOver(q *params) {
+	if p.given == nil || q.given == nil || !p.sameGivenConn(q) {
+		return
+	}
+	if p.given != q.given {
+		p.given.handedOver.Store(true)
+		return
+	}
+	g := q.given
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if q.givenGen > g.owner {
+		g.owner = q.givenGen
+	}
+}
+
+// owned reports whether the backend using g created with generation gen may
+// close the connection. A connection converted from a file by another option
+// is a duplicate owned by the backend using that option.
+func (g *givenConn) owned(gen uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return (g.f != nil || !g.handedOver.Load()) && g.owner == gen
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeReader reads lines sent over the server end of a pipe.
+type pipeReader struct {
+	lines chan string
+	done  chan struct{} // Closed when the client end is closed.
+}
+
+func readPipe(c net.Conn) *pipeReader {
+	r := &pipeReader{lines: make(chan string, 100), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		s := bufio.NewScanner(c)
+		for s.Scan() {
+			r.lines <- s.Text()
+		}
+	}()
+	return r
+}
+
+func (r *pipeReader) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case line := <-r.lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func (r *pipeReader) closed() bool {
+	select {
+	case <-r.done:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestReinitWithConn(t *testing.T) {
+	tests := []struct {
+		name  string
+		reuse bool // Pass the same WithConn option again.
+		opts  []Option
+	}{
+		{"same settings", false, []Option{WithTag("x")}},
+		{"other tag", false, []Option{WithTag("y")}},
+		{"other format", false, []Option{WithTag("x"), WithRFC3164()}},
+		{"async", false, []Option{WithTag("x"), WithAsync(10)}},
+		{"same option", true, []Option{WithTag("x")}},
+		{"same option, other facility", true, []Option{WithTag("x"), WithFacility(syslog.LOG_LOCAL1)}},
+		{"same option, async", true, []Option{WithTag("x"), WithAsync(10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			r := readPipe(server)
+			l := newLogger("")
+			defer l.Close()
+			given := WithConn(client)
+			if err := l.Init(given, WithTag("x")); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.LogE(syslog.LOG_INFO, "first"); err != nil {
+				t.Fatal(err)
+			}
+			r.next(t)
+			if !tt.reuse {
+				given = WithConn(client)
+			}
+			if err := l.Init(append([]Option{given}, tt.opts...)...); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.LogE(syslog.LOG_INFO, "second"); err != nil {
+				t.Fatalf("LogE after re-initialization: %v", err)
+			}
+			l.Flush(context.Background())
+			if line := r.next(t); !strings.Contains(line, "second") {
+				t.Errorf("got %q, want the second message", line)
+			}
+		})
+	}
+}
+
+func TestReinitWithOtherConnClosesGivenConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	r := readPipe(server)
+	other, otherServer := net.Pipe()
+	defer otherServer.Close()
+	go io.Copy(io.Discard, otherServer)
+
+	l := newLogger("")
+	defer l.Close()
+	if err := l.Init(WithConn(client)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Init(WithConn(other)); err != nil {
+		t.Fatal(err)
+	}
+	if !r.closed() {
+		t.Error("connection given first has not been closed")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"encoding/json"
+	"log/syslog"
+	"net/http"
+)
+
+// LevelHandler returns an HTTP handler to inspect and change minimum severity
+// of the default logger and module levels at run time. See
+// Logger.LevelHandler for details.
+func LevelHandler() http.Handler {
+	return std.LevelHandler()
+}
+
+// LevelHandler returns an HTTP handler to inspect and change minimum severity
+// of the logger and module levels (see SetModuleLevel) at run time.
+//
+// GET returns the current state as a JSON object:
+//
+//	{"level":"LOG_INFO","modules":{"storage":"LOG_DEBUG"}}
+//
+// PUT accepts an object of the same form. Both fields are optional, the
+// absent ones are left intact. Severities are accepted in any form known to
+// ParseSeverity. Modules replace all current module levels, so an empty
+// object removes them. The handler responds with the new state.
+//
+// The handler does no authentication, it is up to the caller to make sure it
+// is only reachable by operators.
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(l.serveLevel)
+}
+
+type levelState struct {
+	Level   Severity            `json:"level"`
+	Modules map[string]Severity `json:"modules"`
+}
+
+func (l *Logger) serveLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			Level   *Severity           `json:"level"`
+			Modules map[string]Severity `json:"modules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Level != nil {
+			l.SetMinSeverity(syslog.Priority(*req.Level))
+		}
+		if req.Modules != nil {
+			moduleLevels.update(func(m map[string]syslog.Priority) {
+				for k := range m {
+					delete(m, k)
+				}
+				for k, v := range req.Modules {
+					m[k] = syslog.Priority(v) & severityMask
+				}
+			})
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := levelState{
+		Level:   Severity(l.MinSeverity()),
+		Modules: make(map[string]Severity),
+	}
+	for k, v := range ModuleLevels() {
+		state.Modules[k] = Severity(v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+// appendLEEFBody appends LEEF 1.0 payload for r with attributes attrs to dst:
+//
+//	LEEF:1.0|Vendor|Product|Version|EventID|Attributes
+//
+// Attributes are tab separated key=value pairs starting with sev holding the
+// severity in CEF range and msg holding the message text. Keys are mapped
+// according to p.
+func appendLEEFBody(dst []byte, p *params, r *record, attrs []Attr) []byte {
+	eventID := r.msg
+	for _, a := range attrs {
+		if a.Key == signatureKey && a.Value.kind != KindGroup {
+			eventID = a.Value.String()
+		}
+	}
+	dst = append(dst, "LEEF:1.0|"...)
+	for _, f := range [...]string{p.device.vendor, p.device.product, p.device.version, eventID} {
+		dst = appendCEFHeaderField(dst, f)
+		dst = append(dst, '|')
+	}
+	dst = append(dst, "sev="...)
+	dst = append(dst, cefSeverities[r.severity&severityMask]...)
+	dst = appendLEEFAttr(dst, p, "", String("msg", r.msg))
+	for _, a := range attrs {
+		if a.Key != signatureKey {
+			dst = appendLEEFAttr(dst, p, "", a)
+		}
+	}
+	return dst
+}
+
+// appendLEEFAttr appends a tab followed by a in key=value form to dst. Groups
+// are flattened with keys joined by a dot.
+func appendLEEFAttr(dst []byte, p *params, prefix string, a Attr) []byte {
+	if a.Value.kind == KindGroup {
+		prefix += a.Key + "."
+		for _, ga := range a.Value.Group() {
+			dst = appendLEEFAttr(dst, p, prefix, ga)
+		}
+		return dst
+	}
+	dst = append(dst, '\t')
+	dst = append(dst, p.extensionKey(prefix+a.Key)...)
+	dst = append(dst, '=')
+	v := a.Value.String()
+	for i := 0; i < len(v); i++ {
+		// Tabs separate attributes and line breaks end the message.
+		switch c := v[i]; c {
+		case '\t', '\n', '\r':
+			dst = append(dst, ' ')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SetMinSeverity changes minimum severity of messages sent by the default
+// logger without re-initialization, see WithMinSeverity. It is safe to call
+// SetMinSeverity at any time, e.g. to temporarily enable debug messages in a
+// running service. The next Init resets minimum severity to the one given in
+// its options.
+func SetMinSeverity(severity syslog.Priority) {
+	std.SetMinSeverity(severity)
+}
+
+// MinSeverity returns minimum severity of messages sent by the default logger.
+func MinSeverity() syslog.Priority {
+	return std.MinSeverity()
+}
+
+// SetMinSeverity changes minimum severity of messages sent by the logger, see
+// package level SetMinSeverity.
+func (l *Logger) SetMinSeverity(severity syslog.Priority) {
+	l.minSeverityOffset.Store(int32(syslog.LOG_DEBUG - severity&severityMask))
+}
+
+// MinSeverity returns minimum severity of messages sent by the logger.
+func (l *Logger) MinSeverity() syslog.Priority {
+	return syslog.LOG_DEBUG - syslog.Priority(l.minSeverityOffset.Load())
+}
+
+// levelMap maps names to severities. It is optimized for frequent lookups
+// and rare updates.
+type levelMap struct {
+	mu sync.Mutex // Serializes updates.
+	m  atomic.Pointer[map[string]syslog.Priority]
+}
+
+// load returns the current map which must not be modified.
+func (lm *levelMap) load() map[string]syslog.Priority {
+	if p := lm.m.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// snapshot returns a copy of the current map.
+func (lm *levelMap) snapshot() map[string]syslog.Priority {
+	m := make(map[string]syslog.Priority)
+	for k, v := range lm.load() {
+		m[k] = v
+	}
+	return m
+}
+
+// update replaces the current map with its copy modified by f.
+func (lm *levelMap) update(f func(map[string]syslog.Priority)) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	m := lm.snapshot()
+	f(m)
+	lm.m.Store(&m)
+}
+
+func (lm *levelMap) set(name string, severity syslog.Priority) {
+	lm.update(func(m map[string]syslog.Priority) {
+		m[name] = severity & severityMask
+	})
+}
+
+func (lm *levelMap) clear(name string) {
+	lm.update(func(m map[string]syslog.Priority) {
+		delete(m, name)
+	})
+}
+
+var (
+	moduleLevels levelMap // See SetModuleLevel.
+	tagLevels    levelMap // See SetTagLevel.
+)
+
+// SetModuleLevel overrides minimum severity for messages of a module. A
+// module is either the name of a logger returned by Get or a package sending
+// messages, given as a full import path ("example.com/app/storage") or its
+// last element ("storage"). Logger names are matched first. Module levels
+// take precedence over minimum severity of loggers and apply to all of them,
+// so one chatty subsystem can be muted or made more verbose independently.
+//
+// Matching packages requires a look at the call stack, which makes sending
+// messages noticeably more expensive while any module levels are set.
+func SetModuleLevel(module string, severity syslog.Priority) {
+	moduleLevels.set(module, severity)
+}
+
+// ClearModuleLevel removes the override set by SetModuleLevel for a module.
+func ClearModuleLevel(module string) {
+	moduleLevels.clear(module)
+}
+
+// ModuleLevels returns a copy of all overrides set by SetModuleLevel.
+func ModuleLevels() map[string]syslog.Priority {
+	return moduleLevels.snapshot()
+}
+
+// SetTagLevel overrides minimum severity for messages of loggers initialized
+// with the given tag (see WithTag), e.g. to send only LOG_WARNING and more
+// important messages tagged "healthcheck". Tag levels take precedence over
+// minimum severity of loggers but not over module levels.
+func SetTagLevel(tag string, severity syslog.Priority) {
+	tagLevels.set(tag, severity)
+}
+
+// ClearTagLevel removes the override set by SetTagLevel for a tag.
+func ClearTagLevel(tag string) {
+	tagLevels.clear(tag)
+}
+
+// TagLevels returns a copy of all overrides set by SetTagLevel.
+func TagLevels() map[string]syslog.Priority {
+	return tagLevels.snapshot()
+}
+
+// tagLevel returns minimum severity set by SetTagLevel for the tag of the
+// logger.
+func (l *Logger) tagLevel() (syslog.Priority, bool) {
+	m := tagLevels.load()
+	if len(m) == 0 {
+		return 0, false
+	}
+	w := l.w.Load()
+	if w == nil || w.p.tag == "" {
+		return 0, false
+	}
+	level, ok := m[w.p.tag]
+	return level, ok
+}
+
+// moduleLevel returns minimum severity set by SetModuleLevel for the logger
+// or the package calling it.
+func (l *Logger) moduleLevel() (syslog.Priority, bool) {
+	m := moduleLevels.load()
+	if len(m) == 0 {
+		return 0, false
+	}
+	if l.name != "" {
+		if level, ok := m[l.name]; ok {
+			return level, true
+		}
+	}
+	pkg := callerPackage()
+	if level, ok := m[pkg]; ok {
+		return level, true
+	}
+	level, ok := m[pkg[strings.LastIndexByte(pkg, '/')+1:]]
+	return level, ok
+}
@@ -0,0 +1,565 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/syslog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Logger sends messages to syslog over its own connection. Loggers are
+// obtained with Get or derived from other loggers with With. A logger which
+// has not been initialized yet writes messages to the default log until Init
+// succeeds.
+type Logger struct {
+	*core
+
+	fields []Attr // Attached to every message, see With.
+}
+
+// core is the state shared by a logger and loggers derived from it.
+type core struct {
+	name string // Name the logger is registered under, see Get.
+
+	w atomic.Pointer[writer]
+
+	verbosity atomic.Int32 // See SetVerbosity.
+
+	// Distance from LOG_DEBUG to the minimum severity of messages sent to
+	// syslog, so the zero value enables all messages. See Enabled.
+	minSeverityOffset atomic.Int32
+
+	filtersMu sync.Mutex // Serializes updates of filters.
+	filters   atomic.Pointer[[]filter]
+
+	dropped     [numDropReasons]atomic.Uint64 // See Dropped.
+	purgedBytes atomic.Uint64                 // See PurgedBytes.
+
+	recent atomic.Pointer[ring] // See WithRecentMessages.
+
+	sampler atomic.Pointer[sampler] // See WithSampling.
+	repeats atomic.Pointer[repeats] // See WithRepeatSuppression.
+	dedup   atomic.Pointer[dedup]   // See WithDedupWindow.
+
+	escalator atomic.Pointer[escalator] // See WithEscalation.
+
+	noInitWarningDone       bool
+	failedSyslogWarningDone bool
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Logger)
+)
+
+// Get returns the logger registered under name. If there is no such logger
+// yet, Get creates one so it is fine to call Get during package
+// initialization and Register the logger later, e.g. in main(). Until then
+// the logger writes messages to the default log.
+func Get(name string) *Logger {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	l := registry[name]
+	if l == nil {
+		l = newLogger(name)
+		registry[name] = l
+	}
+	return l
+}
+
+func newLogger(name string) *Logger {
+	return &Logger{core: &core{name: name}}
+}
+
+// Register initializes or re-initializes the logger registered under name
+// with options opts. See Init for details.
+func Register(name string, opts ...Option) error {
+	return Get(name).Init(opts...)
+}
+
+// Init initializes or re-initializes the syslog writer of the logger. It is
+// expected to be safe to call this function from concurrent goroutines.
+//
+// Re-initialization with the same options as the current ones keeps the
+// existing connection. Otherwise a new connection is established first and
+// then atomically replaces the old one. The old connection is closed once the
+// messages being sent through it complete, so no messages are lost during the
+// swap. If the new connection cannot be established the old one stays in use.
+func (l *Logger) Init(opts ...Option) error {
+	return l.InitContext(context.Background(), opts...)
+}
+
+// InitContext is like Init but gives up connecting to syslog service when ctx
+// is done. The connection is not affected by ctx once established.
+func (l *Logger) InitContext(ctx context.Context, opts ...Option) error {
+	p, err := newParams(opts)
+	if err != nil {
+		return err
+	}
+	if old := l.w.Load(); old != nil && old.p.sameConnection(&p) {
+		// Keep the connection and only pick up the remaining options.
+		// The old writer may still be used by concurrent goroutines,
+		// so both are closed at once.
+		w := &writer{b: old.b, p: p, writerState: old.writerState}
+		w.p.current = old.p.current
+		if l.w.CompareAndSwap(old, w) {
+			w.p.current.Store(&w.p)
+			l.apply(&p)
+			return nil
+		}
+	}
+
+	w := &writer{p: p, writerState: new(writerState)}
+	w.p.current.Store(&w.p)
+	b, err := dial(ctx, p, l.drop, l.purge)
+	if err != nil {
+		return err
+	}
+	w.b = b
+	l.apply(&p)
+	if old := l.w.Swap(w); old != nil {
+		old.p.handOver(&p)
+		old.shutdown(context.Background())
+	}
+	return nil
+}
+
+// apply applies options kept by the logger itself rather than its writer.
+func (l *Logger) apply(p *params) {
+	l.SetMinSeverity(p.minSeverity)
+	l.setRecent(p.recentSize)
+	l.setSampling(p.sampling)
+	l.setRepeats(p.repeatTimeout)
+	l.setDedup(p.dedupWindow, p.dedupField)
+	l.setEscalation(p.escalation)
+}
+
+// MustInit is like Init but panics if the syslog writer cannot be initialized.
+func (l *Logger) MustInit(opts ...Option) {
+	if err := l.Init(opts...); err != nil {
+		panic("slog: cannot initialize syslog writer: " + err.Error())
+	}
+}
+
+// Close closes the syslog writer of the logger. See package level Close for
+// details.
+func (l *Logger) Close() error {
+	return l.Shutdown(context.Background())
+}
+
+// Flush waits until messages queued by the logger are sent. See package level
+// Flush for details.
+func (l *Logger) Flush(ctx context.Context) error {
+	if rs := l.repeats.Load(); rs != nil {
+		l.flushRepeats(rs)
+	}
+	if d := l.dedup.Load(); d != nil {
+		d.flush()
+	}
+	w := l.w.Load()
+	if w == nil {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+// Shutdown closes the syslog writer of the logger letting messages which are
+// being sent complete. See package level Shutdown for details.
+func (l *Logger) Shutdown(ctx context.Context) error {
+	if rs := l.repeats.Load(); rs != nil {
+		l.flushRepeats(rs)
+	}
+	if d := l.dedup.Load(); d != nil {
+		d.flush()
+	}
+	old := l.w.Swap(nil)
+	if old == nil {
+		return nil
+	}
+	return old.shutdown(ctx)
+}
+
+// State reports whether messages of the logger are currently sent to syslog.
+// See package level State for details.
+func (l *Logger) State() (initialized bool, network, raddr string) {
+	w := l.w.Load()
+	if w == nil {
+		return false, "", ""
+	}
+	return true, w.p.network, w.p.raddr
+}
+
+// Alert sends a syslog message with severity LOG_ALERT.
+func (l *Logger) Alert(v ...interface{}) {
+	l.log(syslog.LOG_ALERT, v)
+}
+
+// Alertf sends a formatted syslog message with severity LOG_ALERT.
+func (l *Logger) Alertf(format string, v ...interface{}) {
+	l.logf(syslog.LOG_ALERT, format, v)
+}
+
+// Crit sends a syslog message with severity LOG_CRIT.
+func (l *Logger) Crit(v ...interface{}) {
+	l.log(syslog.LOG_CRIT, v)
+}
+
+// Critf sends a formatted syslog message with severity LOG_CRIT.
+func (l *Logger) Critf(format string, v ...interface{}) {
+	l.logf(syslog.LOG_CRIT, format, v)
+}
+
+// Debug sends a syslog message with severity LOG_DEBUG.
+func (l *Logger) Debug(v ...interface{}) {
+	l.log(syslog.LOG_DEBUG, v)
+}
+
+// Debugf sends a formatted syslog message with severity LOG_DEBUG.
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.logf(syslog.LOG_DEBUG, format, v)
+}
+
+// Emerg sends a syslog message with severity LOG_EMERG.
+func (l *Logger) Emerg(v ...interface{}) {
+	l.log(syslog.LOG_EMERG, v)
+}
+
+// Emergf sends a formatted syslog message with severity LOG_EMERG.
+func (l *Logger) Emergf(format string, v ...interface{}) {
+	l.logf(syslog.LOG_EMERG, format, v)
+}
+
+// Err sends a syslog message with severity LOG_ERR.
+func (l *Logger) Err(v ...interface{}) {
+	l.log(syslog.LOG_ERR, v)
+}
+
+// Errf sends a formatted syslog message with severity LOG_ERR.
+func (l *Logger) Errf(format string, v ...interface{}) {
+	l.logf(syslog.LOG_ERR, format, v)
+}
+
+// Info sends a syslog message with severity LOG_INFO.
+func (l *Logger) Info(v ...interface{}) {
+	l.log(syslog.LOG_INFO, v)
+}
+
+// Infof sends a formatted syslog message with severity LOG_INFO.
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.logf(syslog.LOG_INFO, format, v)
+}
+
+// Notice sends a syslog message with severity LOG_NOTICE.
+func (l *Logger) Notice(v ...interface{}) {
+	l.log(syslog.LOG_NOTICE, v)
+}
+
+// Noticef sends a formatted syslog message with severity LOG_NOTICE.
+func (l *Logger) Noticef(format string, v ...interface{}) {
+	l.logf(syslog.LOG_NOTICE, format, v)
+}
+
+// Warning sends a syslog message with severity LOG_WARNING.
+func (l *Logger) Warning(v ...interface{}) {
+	l.log(syslog.LOG_WARNING, v)
+}
+
+// Warningf sends a formatted syslog message with severity LOG_WARNING.
+func (l *Logger) Warningf(format string, v ...interface{}) {
+	l.logf(syslog.LOG_WARNING, format, v)
+}
+
+// Print sends a syslog message with the severity set by WithPrintSeverity.
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Print(v ...interface{}) {
+	l.log(l.printSeverity(), v)
+}
+
+// Printf sends a formatted syslog message with the severity set by
+// WithPrintSeverity. Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.logf(l.printSeverity(), format, v)
+}
+
+// Println sends a syslog message with the severity set by WithPrintSeverity.
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Println(v ...interface{}) {
+	if severity := l.printSeverity(); l.Enabled(severity) {
+		l.write(severity, sprintln(v), nil)
+	} else if l.recording() {
+		l.remember(severity, sprintln(v), nil)
+	}
+}
+
+func (l *Logger) printSeverity() syslog.Priority {
+	if w := l.w.Load(); w != nil {
+		return w.p.printSeverity
+	}
+	return defaultPrintSeverity
+}
+
+// Log sends a syslog message with the given severity. Facility bits of
+// severity, if any, are ignored.
+func (l *Logger) Log(severity syslog.Priority, v ...interface{}) {
+	l.log(severity&severityMask, v)
+}
+
+// Logf sends a formatted syslog message with the given severity. Facility
+// bits of severity, if any, are ignored.
+func (l *Logger) Logf(severity syslog.Priority, format string, v ...interface{}) {
+	l.logf(severity&severityMask, format, v)
+}
+
+// Enabled reports whether messages with the given severity are sent by the
+// logger. It can be used to skip expensive preparation of messages which
+// would be discarded anyway.
+func (l *Logger) Enabled(severity syslog.Priority) bool {
+	if level, ok := l.moduleLevel(); ok {
+		return severity&severityMask <= level
+	}
+	if level, ok := l.tagLevel(); ok {
+		return severity&severityMask <= level
+	}
+	return int32(severity&severityMask) <= int32(syslog.LOG_DEBUG)-l.minSeverityOffset.Load()
+}
+
+// log formats and sends a message unless severity is disabled.
+func (l *Logger) log(severity syslog.Priority, v []interface{}) {
+	if l.Enabled(severity) {
+		l.write(severity, sprint(v), nil)
+	} else if l.recording() {
+		l.remember(severity, sprint(v), nil)
+	}
+}
+
+// logf formats and sends a message unless severity is disabled.
+func (l *Logger) logf(severity syslog.Priority, format string, v []interface{}) {
+	if l.Enabled(severity) {
+		l.write(severity, sprintf(format, v), nil)
+	} else if l.recording() {
+		l.remember(severity, sprintf(format, v), nil)
+	}
+}
+
+// sprint is like fmt.Sprint but it returns a single string argument as is,
+// so constant messages cost no allocation.
+func sprint(v []interface{}) string {
+	if len(v) == 1 {
+		if s, ok := v[0].(string); ok {
+			return s
+		}
+	}
+	return fmt.Sprint(v...)
+}
+
+// sprintf is like fmt.Sprintf but it returns format without verbs as is if
+// there are no arguments.
+func sprintf(format string, v []interface{}) string {
+	if len(v) == 0 && strings.IndexByte(format, '%') < 0 {
+		return format
+	}
+	return fmt.Sprintf(format, v...)
+}
+
+// sprintln is like fmt.Sprintln without the trailing line break, and it
+// returns a single string argument as is.
+func sprintln(v []interface{}) string {
+	if len(v) == 1 {
+		if s, ok := v[0].(string); ok {
+			return s
+		}
+	}
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+// LogE is like Log but returns an error if the message has not been delivered
+// to syslog and has been written to the default log instead. ErrNotInitialized
+// is returned if the logger has no syslog writer. Messages discarded because
+// their severity is disabled are not considered an error.
+func (l *Logger) LogE(severity syslog.Priority, v ...interface{}) error {
+	severity &= severityMask
+	if !l.Enabled(severity) {
+		if l.recording() {
+			l.remember(severity, sprint(v), nil)
+		}
+		return nil
+	}
+	return l.write(severity, sprint(v), nil)
+}
+
+// LogfE is like Logf but returns an error if the message has not been
+// delivered to syslog, see LogE.
+func (l *Logger) LogfE(severity syslog.Priority, format string, v ...interface{}) error {
+	severity &= severityMask
+	if !l.Enabled(severity) {
+		if l.recording() {
+			l.remember(severity, sprintf(format, v), nil)
+		}
+		return nil
+	}
+	return l.write(severity, sprintf(format, v), nil)
+}
+
+// write sends the message with attributes attrs and the ones attached to the
+// logger to syslog and falls back to the default log if that fails. The
+// returned error explains why the message has not been delivered to syslog.
+// Filters see msg only, not the attributes.
+func (l *Logger) write(severity syslog.Priority, msg string, attrs []Attr) error {
+	return l.output(severity, msg, attrs, false)
+}
+
+// output is like write but if try is set, it neither blocks nor falls back to
+// the default log, see TryLog.
+func (l *Logger) output(severity syslog.Priority, msg string, attrs []Attr, try bool) error {
+	severity = l.escalate(severity, msg, attrs)
+	w := l.w.Load()
+	if w != nil && w.p.denied(msg) || l.filtered(severity, msg) || l.sampledOut(w, severity, msg) ||
+		l.rateLimited(w) || l.deduplicated(severity, msg, attrs) || l.repeated(w, severity, msg, attrs, try) {
+		l.remember(severity, msg, attrs)
+		return nil
+	}
+	return l.emit(w, severity, msg, attrs, try)
+}
+
+// emit sends a message which has passed the filters with w, see output.
+func (l *Logger) emit(w *writer, severity syslog.Priority, msg string, attrs []Attr, try bool) error {
+	recent := l.recent.Load()
+	if w == nil {
+		r := l.newRecord(severity, msg, attrs)
+		defer r.free()
+		if recent != nil {
+			recent.add(r)
+		}
+		if try {
+			return ErrNotInitialized
+		}
+		return l.send(w, r)
+	}
+	if w.p.caller {
+		attrs = append(attrs[:len(attrs):len(attrs)], String("caller", caller(w.p.callerSkip)))
+	}
+	if w.p.stacktrace && severity <= w.p.stacktraceSeverity {
+		attrs = append(attrs[:len(attrs):len(attrs)], String("stacktrace", stacktrace()))
+	}
+	var prefix string
+	if len(w.p.prefix) > 0 {
+		prefix = w.p.expandPrefix(l, severity)
+	}
+	var err error
+	var buf [1]string
+	for _, line := range w.p.appendLines(buf[:0], msg) {
+		if prefix != "" {
+			line = prefix + line
+		}
+		r := l.newRecord(severity, line, attrs)
+		if w.p.normalize != nil {
+			r.msg = w.p.normalize(r.msg)
+			r.attrs = normalizeAttrs(r.attrs, w.p.normalize)
+		}
+		if w.p.redactor.enabled() {
+			w.p.redactor.redactRecord(r)
+		}
+		if recent != nil {
+			recent.add(r)
+		}
+		if w.p.validate {
+			if e := validateRecord(r); e != nil {
+				if w.p.errorHandler != nil {
+					w.p.errorHandler(e)
+				}
+				if w.p.dropInvalid {
+					l.drop(&w.p, 1, DropInvalid)
+					if err == nil {
+						err = e
+					}
+					r.free()
+					continue
+				}
+			}
+		}
+		var e error
+		if try {
+			e = l.trySend(w, r)
+		} else {
+			e = l.send(w, r)
+		}
+		if err == nil {
+			err = e
+		}
+		r.free()
+	}
+	return err
+}
+
+// trySend sends r with w if it can be done without blocking.
+func (l *Logger) trySend(w *writer, r *record) error {
+	err := w.trySend(r)
+	switch err {
+	case nil, ErrNotInitialized:
+	case errWouldBlock, ErrQueueFull:
+		l.drop(&w.p, 1, DropOverflow)
+	default:
+		l.drop(&w.p, 1, DropSendFailed)
+	}
+	return err
+}
+
+// send sends r with w or writes it to the default log if w is nil or fails.
+func (l *Logger) send(w *writer, r *record) error {
+	err := ErrNotInitialized
+	if w != nil {
+		err = w.send(r)
+	}
+	if err == ErrDropped {
+		l.drop(&w.p, 1, DropOverflow)
+		return err
+	}
+	if err == ErrRateLimited {
+		// Counted by the backend.
+		return err
+	}
+	if err == ErrNotInitialized {
+		if !l.noInitWarningDone {
+			log.Print("Log requests before syslog.Init are sent to default log.")
+			l.noInitWarningDone = true
+		}
+		log.Print(r.text())
+		return err
+	}
+	if err != nil {
+		if err == ErrQueueFull {
+			l.drop(&w.p, 1, DropOverflow)
+		} else {
+			l.drop(&w.p, 1, DropSendFailed)
+		}
+		if w.p.errorHandler != nil && err != ErrCircuitOpen {
+			// The circuit is reported by WithCircuitHandler.
+			w.p.errorHandler(err)
+		}
+		if !l.failedSyslogWarningDone {
+			log.Print("Error sending message to syslog: ", err)
+			l.failedSyslogWarningDone = true
+		}
+		log.Print(r.text())
+		return err
+	}
+	l.failedSyslogWarningDone = false
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"fmt"
+	"log/syslog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInitReusesConnection(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		keep bool
+	}{
+		{"same options", nil, true},
+		{"other tag", []Option{WithTag("other")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Skip(err)
+			}
+			defer c.Close()
+			// source returns the address the next message comes from.
+			source := func() string {
+				t.Helper()
+				c.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, addr, err := c.ReadFrom(make([]byte, 1024))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return addr.String()
+			}
+			opts := []Option{WithDial("udp", c.LocalAddr().String()), WithTag("test")}
+			l := newLogger("")
+			defer l.Close()
+			if err := l.Init(opts...); err != nil {
+				t.Fatal(err)
+			}
+			l.Info("first")
+			first := source()
+			if err := l.Init(append(opts, tt.opts...)...); err != nil {
+				t.Fatal(err)
+			}
+			l.Info("second")
+			if kept := source() == first; kept != tt.keep {
+				t.Errorf("connection kept = %v, want %v", kept, tt.keep)
+			}
+		})
+	}
+}
+
+func TestReinitKeepsConnection(t *testing.T) {
+	// Nothing listens at addr, so spooled messages are replayed in vain
+	// and errors are reported in background.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	tests := []struct {
+		name string
+		opts []Option
+		keep bool
+	}{
+		{"same options", nil, true},
+		{"other dial timeout", []Option{WithDialTimeout(time.Second)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := func(errs chan error) []Option {
+				return []Option{
+					WithDial("tcp", addr),
+					WithSpool(dir),
+					WithReconnect(RetryPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond}),
+					WithErrorHandler(func(err error) {
+						select {
+						case errs <- err:
+						default:
+						}
+					}),
+				}
+			}
+			l := newLogger("")
+			defer l.Close()
+			if err := l.Init(opts(make(chan error, 1))...); err != nil {
+				t.Fatal(err)
+			}
+			b := l.w.Load().b
+			errs := make(chan error, 1)
+			if err := l.Init(append(opts(errs), tt.opts...)...); err != nil {
+				t.Fatal(err)
+			}
+			if kept := l.w.Load().b == b; kept != tt.keep {
+				t.Fatalf("connection kept = %v, want %v", kept, tt.keep)
+			}
+			select {
+			case <-errs:
+			case <-time.After(5 * time.Second):
+				t.Error("no error reported to the handler set by re-initialization")
+			}
+		})
+	}
+}
+
+func TestReinitSharesSpool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	dir := t.TempDir()
+	opts := func(tag string) []Option {
+		return []Option{
+			WithDial("tcp", addr),
+			WithTag(tag),
+			WithSpool(dir),
+			WithReconnect(RetryPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond}),
+			WithErrorHandler(func(error) {}),
+		}
+	}
+	l := newLogger("")
+	defer l.Close()
+	const n = 20
+	for i := 0; i < n; i++ {
+		if i == n/2 {
+			// Messages spooled so far are pending when the writer is
+			// replaced.
+			if err := l.Init(opts("y")...); err != nil {
+				t.Fatal(err)
+			}
+		} else if i == 0 {
+			if err := l.Init(opts("x")...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.LogE(syslog.LOG_INFO, fmt.Sprintf("message %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectReplayed(t, addr, n)
+}
+
+func TestReinitKeepsConnectionClosed(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	readPipe(server)
+	l := newLogger("")
+	given := WithConn(client)
+	if err := l.Init(given, WithAsync(10)); err != nil {
+		t.Fatal(err)
+	}
+	// A goroutine may still use the writer replaced by Init keeping the
+	// connection after the logger is shut down.
+	old := l.w.Load()
+	if err := l.Init(given, WithAsync(10), WithMinSeverity(syslog.LOG_INFO)); err != nil {
+		t.Fatal(err)
+	}
+	if l.w.Load().b != old.b {
+		t.Fatal("connection not kept")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := &record{time: time.Now(), severity: syslog.LOG_INFO, msg: "late"}
+	if err := old.send(r); err != ErrNotInitialized {
+		t.Errorf("send = %v, want %v", err, ErrNotInitialized)
+	}
+	if err := old.trySend(r); err != ErrNotInitialized {
+		t.Errorf("trySend = %v, want %v", err, ErrNotInitialized)
+	}
+}
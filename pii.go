@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Detector finds personal data of some kind in text for scrubbing, see
+// WithPIIScrubber.
+type Detector struct {
+	// Kind names the data in the mask replacing it, e.g. "email" gives
+	// "[email]".
+	Kind string
+
+	// Find returns byte offsets of the data in s as pairs of start and end
+	// offsets in increasing order, like regexp.Regexp.FindAllStringIndex.
+	Find func(s string) [][]int
+}
+
+// RegexpDetector returns a Detector which finds text matching re.
+func RegexpDetector(kind string, re *regexp.Regexp) Detector {
+	return Detector{kind, func(s string) [][]int {
+		return re.FindAllStringIndex(s, -1)
+	}}
+}
+
+var (
+	emailRe  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	cardRe   = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	usSSNRe  = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+	ukNINORe = regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)
+)
+
+// Detectors of common kinds of personal data.
+var (
+	// EmailDetector finds email addresses.
+	EmailDetector = RegexpDetector("email", emailRe)
+
+	// CardNumberDetector finds payment card numbers of 13 to 19 digits,
+	// possibly grouped with spaces or dashes, which pass the Luhn check.
+	CardNumberDetector = Detector{"card", findCardNumbers}
+
+	// USSSNDetector finds US social security numbers in the form
+	// AAA-GG-SSSS, excluding numbers which are never assigned.
+	USSSNDetector = Detector{"ssn", findUSSSNs}
+
+	// UKNINODetector finds UK national insurance numbers.
+	UKNINODetector = RegexpDetector("nino", ukNINORe)
+)
+
+func findCardNumbers(s string) [][]int {
+	ms := cardRe.FindAllStringIndex(s, -1)
+	n := 0
+	for _, m := range ms {
+		if luhn(s[m[0]:m[1]]) {
+			ms[n] = m
+			n++
+		}
+	}
+	return ms[:n]
+}
+
+// luhn reports whether digits in s pass the Luhn check. Other characters are
+// ignored.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func findUSSSNs(s string) [][]int {
+	ms := usSSNRe.FindAllStringSubmatchIndex(s, -1)
+	n := 0
+	for _, m := range ms {
+		area, group, serial := s[m[2]:m[3]], s[m[4]:m[5]], s[m[6]:m[7]]
+		if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+			continue
+		}
+		ms[n] = m[:2]
+		n++
+	}
+	return ms[:n]
+}
+
+// scrub returns s with data found by d replaced with its mask.
+func (d *Detector) scrub(s string) string {
+	ms := d.Find(s)
+	if len(ms) == 0 {
+		return s
+	}
+	mask := "[" + d.Kind + "]"
+	var b strings.Builder
+	last := 0
+	for _, m := range ms {
+		if m[0] < last {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(mask)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// poolBackend distributes messages across several connections, see
+// WithConnPool.
+type poolBackend struct {
+	conns []*connBackend
+	next  atomic.Uint32
+}
+
+// dialPool creates a poolBackend with p.poolSize connections.
+func dialPool(ctx context.Context, p params, s *spool, drop dropFunc) (*poolBackend, error) {
+	b := &poolBackend{conns: make([]*connBackend, 0, p.poolSize)}
+	for i := 0; i < p.poolSize; i++ {
+		c, err := dialConn(ctx, p, s, drop)
+		if err != nil {
+			b.close()
+			return nil, err
+		}
+		b.conns = append(b.conns, c)
+	}
+	return b, nil
+}
+
+func (b *poolBackend) send(p *params, r *record) error {
+	return b.sendBatch(p, []*record{r})
+}
+
+func (b *poolBackend) sendBatch(p *params, rs []*record) error {
+	start := int(b.next.Add(1)-1) % len(b.conns)
+	var err error
+	for i := range b.conns {
+		// Skip connections being restored, see WithReconnect.
+		err = b.conns[(start+i)%len(b.conns)].sendBatch(p, rs)
+		if !errors.Is(err, errReconnecting) {
+			return err
+		}
+	}
+	return err
+}
+
+// close closes all connections and returns the first error.
+func (b *poolBackend) close() error {
+	var err error
+	for _, c := range b.conns {
+		if cerr := c.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoolSpoolOrder(t *testing.T) {
+	// Nothing listens at addr, so the first connection spools messages.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	dir := t.TempDir()
+	p, err := newParams([]Option{
+		WithDial("tcp", addr),
+		WithSpool(dir),
+		WithReconnect(RetryPolicy{Initial: time.Hour}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := openSpool(&p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop := func(p *params, n int, reason DropReason) {}
+	down, err := dialConn(context.Background(), p, s, drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second connection of the pool works.
+	client, server := net.Pipe()
+	defer server.Close()
+	r := readPipe(server)
+	q, err := newParams([]Option{WithConn(client), WithSpool(dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, err := dialConn(context.Background(), q, s, drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, b := range []*connBackend{down, up} {
+		msg := []string{"first", "second"}[i]
+		if err := b.send(&p, &record{time: time.Now(), severity: syslog.LOG_INFO, msg: msg}); err != nil {
+			t.Fatalf("sending %s message: %v", msg, err)
+		}
+	}
+	select {
+	case line := <-r.lines:
+		t.Errorf("%q sent before the spooled message", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+	up.close()
+	down.close()
+	s.close()
+	s, err = openSpool(&p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := replayAll(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !strings.HasSuffix(got[0], "first") || !strings.HasSuffix(got[1], "second") {
+		t.Errorf("spooled %q, want the first and the second message", got)
+	}
+}
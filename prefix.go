@@ -0,0 +1,154 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// prefixField is a placeholder of a prefix template, see WithPrefixTemplate.
+type prefixField int
+
+const (
+	prefixText prefixField = iota
+	prefixTag
+	prefixSeverity
+	prefixFacility
+	prefixHostname
+	prefixPID
+	prefixGoroutine
+	prefixLogger
+	prefixCaller
+)
+
+var prefixFields = map[string]prefixField{
+	"tag":       prefixTag,
+	"severity":  prefixSeverity,
+	"facility":  prefixFacility,
+	"hostname":  prefixHostname,
+	"pid":       prefixPID,
+	"goroutine": prefixGoroutine,
+	"logger":    prefixLogger,
+	"caller":    prefixCaller,
+}
+
+// prefixPart is either literal text or a placeholder of a prefix template.
+type prefixPart struct {
+	field prefixField
+	text  string
+}
+
+// parsePrefixTemplate splits template into literal text and placeholders.
+func parsePrefixTemplate(template string) ([]prefixPart, error) {
+	var parts []prefixPart
+	var text strings.Builder
+	for s := template; s != ""; {
+		i := strings.IndexAny(s, "{}")
+		if i < 0 {
+			text.WriteString(s)
+			break
+		}
+		text.WriteString(s[:i])
+		if i+1 < len(s) && s[i+1] == s[i] {
+			// Escaped brace.
+			text.WriteByte(s[i])
+			s = s[i+2:]
+			continue
+		}
+		if s[i] == '}' {
+			return nil, fmt.Errorf("unexpected '}' in prefix template %q", template)
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in prefix template %q", template)
+		}
+		name := s[i+1 : i+j]
+		field, ok := prefixFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} in prefix template %q", name, template)
+		}
+		if text.Len() > 0 {
+			parts = append(parts, prefixPart{text: text.String()})
+			text.Reset()
+		}
+		parts = append(parts, prefixPart{field: field})
+		s = s[i+j+1:]
+	}
+	if text.Len() > 0 {
+		parts = append(parts, prefixPart{text: text.String()})
+	}
+	return parts, nil
+}
+
+// expandPrefix returns the prefix template expanded for a message with
+// severity sent by l.
+func (p *params) expandPrefix(l *Logger, severity syslog.Priority) string {
+	var b strings.Builder
+	for _, part := range p.prefix {
+		switch part.field {
+		case prefixText:
+			b.WriteString(part.text)
+		case prefixTag:
+			if p.tag != "" {
+				b.WriteString(p.tag)
+			} else {
+				b.WriteString(filepath.Base(os.Args[0]))
+			}
+		case prefixSeverity:
+			b.WriteString(strings.TrimPrefix(SeverityString(severity), priorityStrPrefix))
+		case prefixFacility:
+			b.WriteString(strings.TrimPrefix(FacilityString(p.facility), priorityStrPrefix))
+		case prefixHostname:
+			if p.hostname != "" {
+				b.WriteString(p.hostname)
+			} else {
+				b.WriteString(localHostname())
+			}
+		case prefixPID:
+			b.WriteString(strconv.Itoa(os.Getpid()))
+		case prefixGoroutine:
+			b.WriteString(goroutineID())
+		case prefixLogger:
+			b.WriteString(l.name)
+		case prefixCaller:
+			b.WriteString(caller(p.callerSkip))
+		}
+	}
+	return b.String()
+}
+
+var goroutineBufPool = sync.Pool{
+	New: func() interface{} { return new([64]byte) },
+}
+
+// goroutineID returns the identifier of the calling goroutine, which is
+// available only from the header of its stack trace, "goroutine N [...".
+func goroutineID() string {
+	buf := goroutineBufPool.Get().(*[64]byte)
+	defer goroutineBufPool.Put(buf)
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// facilityMask selects facility bits of syslog.Priority.
+const facilityMask = 0xf8
+
+var facilityNames = map[syslog.Priority]string{
+	syslog.LOG_KERN:     "LOG_KERN",
+	syslog.LOG_USER:     "LOG_USER",
+	syslog.LOG_MAIL:     "LOG_MAIL",
+	syslog.LOG_DAEMON:   "LOG_DAEMON",
+	syslog.LOG_AUTH:     "LOG_AUTH",
+	syslog.LOG_SYSLOG:   "LOG_SYSLOG",
+	syslog.LOG_LPR:      "LOG_LPR",
+	syslog.LOG_NEWS:     "LOG_NEWS",
+	syslog.LOG_UUCP:     "LOG_UUCP",
+	syslog.LOG_CRON:     "LOG_CRON",
+	syslog.LOG_AUTHPRIV: "LOG_AUTHPRIV",
+	syslog.LOG_FTP:      "LOG_FTP",
+	syslog.LOG_LOCAL0:   "LOG_LOCAL0",
+	syslog.LOG_LOCAL1:   "LOG_LOCAL1",
+	syslog.LOG_LOCAL2:   "LOG_LOCAL2",
+	syslog.LOG_LOCAL3:   "LOG_LOCAL3",
+	syslog.LOG_LOCAL4:   "LOG_LOCAL4",
+	syslog.LOG_LOCAL5:   "LOG_LOCAL5",
+	syslog.LOG_LOCAL6:   "LOG_LOCAL6",
+	syslog.LOG_LOCAL7:   "LOG_LOCAL7",
+}
+
+var severityNames = [...]string{
+	syslog.LOG_EMERG:   "LOG_EMERG",
+	syslog.LOG_ALERT:   "LOG_ALERT",
+	syslog.LOG_CRIT:    "LOG_CRIT",
+	syslog.LOG_ERR:     "LOG_ERR",
+	syslog.LOG_WARNING: "LOG_WARNING",
+	syslog.LOG_NOTICE:  "LOG_NOTICE",
+	syslog.LOG_INFO:    "LOG_INFO",
+	syslog.LOG_DEBUG:   "LOG_DEBUG",
+}
+
+// FacilityString returns symbolic name of the facility part of p, e.g.
+// "LOG_DAEMON". The name is accepted by ParseFacility. Facilities not known to
+// ParseFacility are returned in the form "facility(N)".
+func FacilityString(p syslog.Priority) string {
+	f := p & facilityMask
+	if name, ok := facilityNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("facility(%d)", f>>3)
+}
+
+// SeverityString returns symbolic name of the severity part of p, e.g.
+// "LOG_INFO". The name is accepted by ParseSeverity.
+func SeverityString(p syslog.Priority) string {
+	return severityNames[p&severityMask]
+}
+
+// Facility is a syslog facility which is represented by its symbolic name
+// when marshaled to text, e.g. in JSON or other configuration formats.
+type Facility syslog.Priority
+
+// String returns symbolic name of the facility, see FacilityString.
+func (f Facility) String() string {
+	return FacilityString(syslog.Priority(f))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f Facility) MarshalText() ([]byte, error) {
+	if _, ok := facilityNames[syslog.Priority(f)]; !ok {
+		return nil, fmt.Errorf("cannot marshal unknown syslog facility %d", f)
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the same
+// names as ParseFacility.
+func (f *Facility) UnmarshalText(text []byte) error {
+	p, err := ParseFacility(string(text))
+	if err != nil {
+		return err
+	}
+	*f = Facility(p)
+	return nil
+}
+
+// Severity is a syslog severity which is represented by its symbolic name
+// when marshaled to text, e.g. in JSON or other configuration formats.
+type Severity syslog.Priority
+
+// String returns symbolic name of the severity, see SeverityString.
+func (s Severity) String() string {
+	return SeverityString(syslog.Priority(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s&^severityMask != 0 {
+		return nil, fmt.Errorf("cannot marshal unknown syslog severity %d", s)
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the same
+// names as ParseSeverity.
+func (s *Severity) UnmarshalText(text []byte) error {
+	p, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = Severity(p)
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// parseProxy parses the URL given to WithProxy.
+func parseProxy(network, rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %v", err)
+	}
+	switch u.Scheme {
+	case "socks5", "http":
+	default:
+		return nil, fmt.Errorf("proxy: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy: missing host in %q", rawURL)
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("proxy: network %q is not supported, see WithDial", network)
+	}
+	return u, nil
+}
+
+// proxyAddr returns the address of proxy u with the default port if the URL
+// has none.
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "1080"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// proxyConnect asks proxy u connected with c to open a tunnel to addr.
+func proxyConnect(ctx context.Context, c net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Unblock reads and writes of the handshake below.
+			c.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	tc := c
+	var err error
+	if u.Scheme == "http" {
+		tc, err = httpConnect(c, u, addr)
+	} else {
+		err = socks5Connect(c, u, addr)
+	}
+	close(done)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("proxy %s: %v", u.Host, err)
+	}
+	c.SetDeadline(time.Time{})
+	return tc, nil
+}
+
+// httpConnect opens a tunnel with HTTP CONNECT method.
+func httpConnect(c net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req += "Proxy-Authorization: Basic " + auth + "\r\n"
+	}
+	if _, err := io.WriteString(c, req+"\r\n"); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{c, br}, nil
+	}
+	return c, nil
+}
+
+// bufferedConn is a net.Conn with data already read from it into a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// SOCKS version 5 constants from RFC 1928 and RFC 1929.
+const (
+	socks5Version      = 5
+	socks5NoAuth       = 0
+	socks5PasswordAuth = 2
+	socks5CmdConnect   = 1
+	socks5IPv4         = 1
+	socks5Domain       = 3
+	socks5IPv6         = 4
+)
+
+var socks5Errors = []string{
+	"",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// socks5Connect opens a tunnel with SOCKS5 CONNECT command. Host names are
+// resolved by the proxy.
+func socks5Connect(c net.Conn, u *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+
+	method := byte(socks5NoAuth)
+	if u.User != nil {
+		method = socks5PasswordAuth
+	}
+	if _, err := c.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	b := make([]byte, 2, 256)
+	if _, err := io.ReadFull(c, b); err != nil {
+		return err
+	}
+	if b[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version %d", b[0])
+	}
+	if b[1] != method {
+		return errors.New("no acceptable authentication method")
+	}
+	if method == socks5PasswordAuth {
+		user := u.User.Username()
+		password, _ := u.User.Password()
+		if len(user) > 255 || len(password) > 255 {
+			return errors.New("user name or password is too long")
+		}
+		b = append(b[:0], 1, byte(len(user)))
+		b = append(b, user...)
+		b = append(b, byte(len(password)))
+		b = append(b, password...)
+		if _, err := c.Write(b); err != nil {
+			return err
+		}
+		b = b[:2]
+		if _, err := io.ReadFull(c, b); err != nil {
+			return err
+		}
+		if b[1] != 0 {
+			return errors.New("authentication failed")
+		}
+	}
+
+	b = append(b[:0], socks5Version, socks5CmdConnect, 0)
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long", host)
+		}
+		b = append(b, socks5Domain, byte(len(host)))
+		b = append(b, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		b = append(b, socks5IPv4)
+		b = append(b, ip4...)
+	} else {
+		b = append(b, socks5IPv6)
+		b = append(b, ip...)
+	}
+	b = append(b, byte(port>>8), byte(port))
+	if _, err := c.Write(b); err != nil {
+		return err
+	}
+
+	// Reply is version, status, reserved byte and the bound address.
+	b = b[:4]
+	if _, err := io.ReadFull(c, b); err != nil {
+		return err
+	}
+	if b[1] != 0 {
+		if int(b[1]) < len(socks5Errors) {
+			return errors.New(socks5Errors[b[1]])
+		}
+		return fmt.Errorf("unknown SOCKS error %d", b[1])
+	}
+	var n int
+	switch b[3] {
+	case socks5IPv4:
+		n = net.IPv4len
+	case socks5IPv6:
+		n = net.IPv6len
+	case socks5Domain:
+		if _, err := io.ReadFull(c, b[:1]); err != nil {
+			return err
+		}
+		n = int(b[0])
+	default:
+		return fmt.Errorf("unknown address type %d", b[3])
+	}
+	_, err = io.ReadFull(c, b[:n+2])
+	return err
+}
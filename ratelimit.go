@@ -0,0 +1,199 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenBucket allows events at a given average rate with bursts.
+type tokenBucket struct {
+	rate  float64 // Tokens added per second.
+	burst float64 // Maximum number of tokens.
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // When tokens were last added.
+}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// allow takes a token and reports whether there was one.
+func (b *tokenBucket) allow() bool {
+	return b.allowN(1)
+}
+
+// allowN takes n tokens and reports whether there were enough of them. More
+// tokens than the burst size are available once the bucket is full.
+func (b *tokenBucket) allowN(n int) bool {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fill(now)
+	need := b.need(n)
+	if b.tokens < need {
+		return false
+	}
+	b.tokens -= need
+	return true
+}
+
+// fill adds tokens accumulated until now. It must be called with b.mu held.
+func (b *tokenBucket) fill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// need returns the number of tokens taken by allowN(n).
+func (b *tokenBucket) need(n int) float64 {
+	if need := float64(n); need < b.burst {
+		return need
+	}
+	return b.burst
+}
+
+// tagLimits maps tags to their rate limits, see SetTagRateLimit. Like
+// levelMap, it is optimized for frequent lookups and rare updates.
+var tagLimits struct {
+	mu sync.Mutex // Serializes updates.
+	m  atomic.Pointer[map[string]*tokenBucket]
+}
+
+// updateTagLimits replaces tagLimits with a copy modified by f.
+func updateTagLimits(f func(map[string]*tokenBucket)) {
+	tagLimits.mu.Lock()
+	defer tagLimits.mu.Unlock()
+	m := make(map[string]*tokenBucket)
+	if p := tagLimits.m.Load(); p != nil {
+		for k, v := range *p {
+			m[k] = v
+		}
+	}
+	f(m)
+	tagLimits.m.Store(&m)
+}
+
+// SetTagRateLimit limits messages of loggers initialized with the given tag
+// (see WithTag) to perSecond on average with bursts of up to burst messages.
+// Messages exceeding the limit are discarded and counted as DropRateLimited.
+// Each tag has its own budget shared by all loggers with the tag, so one
+// chatty component cannot starve others of syslog bandwidth. Setting the
+// limit again starts with a full budget.
+func SetTagRateLimit(tag string, perSecond float64, burst int) {
+	b := newTokenBucket(perSecond, burst)
+	updateTagLimits(func(m map[string]*tokenBucket) {
+		m[tag] = b
+	})
+}
+
+// ClearTagRateLimit removes the limit set by SetTagRateLimit for a tag.
+func ClearTagRateLimit(tag string) {
+	updateTagLimits(func(m map[string]*tokenBucket) {
+		delete(m, tag)
+	})
+}
+
+// rateLimited reports whether the message exceeds the rate limit of the tag
+// of w, see SetTagRateLimit, and counts it as dropped then.
+func (l *Logger) rateLimited(w *writer) bool {
+	p := tagLimits.m.Load()
+	if p == nil || len(*p) == 0 || w == nil || w.p.tag == "" {
+		return false
+	}
+	b := (*p)[w.p.tag]
+	if b == nil || b.allow() {
+		return false
+	}
+	l.drop(&w.p, 1, DropRateLimited)
+	return true
+}
+
+// globalLimits holds the limits of all loggers, see SetRateLimit and
+// SetByteRateLimit.
+var globalLimits struct {
+	messages atomic.Pointer[tokenBucket]
+	bytes    atomic.Pointer[tokenBucket]
+}
+
+// ErrRateLimited is returned by LogE and LogfE when a message is discarded
+// because it exceeds the limits set with SetRateLimit and SetByteRateLimit.
+var ErrRateLimited = errors.New("slog: message rate limit exceeded")
+
+// SetRateLimit limits messages sent to syslog by all loggers of the program
+// to perSecond on average with bursts of up to burst messages, e.g. to stay
+// within the volume a syslog relay accepts from each sender. Messages
+// exceeding the limit are discarded before they reach the connection, counted
+// as DropRateLimited and LogE returns ErrRateLimited for them. Zero or
+// negative perSecond removes the limit. Messages written to the default log
+// are not limited.
+func SetRateLimit(perSecond float64, burst int) {
+	setGlobalLimit(&globalLimits.messages, perSecond, burst)
+}
+
+// SetByteRateLimit is like SetRateLimit but limits the size of messages
+// formatted for sending to bytesPerSecond with bursts of up to burst bytes.
+// Both limits apply if both are set.
+func SetByteRateLimit(bytesPerSecond float64, burst int) {
+	setGlobalLimit(&globalLimits.bytes, bytesPerSecond, burst)
+}
+
+func setGlobalLimit(limit *atomic.Pointer[tokenBucket], perSecond float64, burst int) {
+	if perSecond <= 0 {
+		limit.Store(nil)
+		return
+	}
+	limit.Store(newTokenBucket(perSecond, burst))
+}
+
+// overLimit reports whether sending a message of size bytes formatted for
+// sending exceeds the limits set with SetRateLimit and SetByteRateLimit.
+// Tokens are taken from neither limit unless both allow the message.
+func overLimit(size int) bool {
+	mb, bb := globalLimits.messages.Load(), globalLimits.bytes.Load()
+	switch {
+	case mb == nil && bb == nil:
+		return false
+	case bb == nil:
+		return !mb.allow()
+	case mb == nil:
+		return !bb.allowN(size)
+	}
+	now := time.Now()
+	// Always locked in this order.
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	mb.fill(now)
+	bb.fill(now)
+	m, n := mb.need(1), bb.need(size)
+	if mb.tokens < m || bb.tokens < n {
+		return true
+	}
+	mb.tokens -= m
+	bb.tokens -= n
+	return false
+}
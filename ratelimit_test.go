@@ -0,0 +1,78 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverLimit(t *testing.T) {
+	defer SetRateLimit(0, 0)
+	defer SetByteRateLimit(0, 0)
+	// Practically no tokens are added during the test.
+	SetRateLimit(1e-9, 2)
+	SetByteRateLimit(1e-9, 10)
+	steps := []struct {
+		size int
+		want bool
+	}{
+		{6, false},
+		{6, true}, // Takes no message token.
+		{4, false},
+		{1, true},
+	}
+	for i, s := range steps {
+		if got := overLimit(s.size); got != s.want {
+			t.Errorf("step %d: overLimit(%d) = %v, want %v", i, s.size, got, s.want)
+		}
+	}
+}
+
+func TestRateLimitDrops(t *testing.T) {
+	defer SetRateLimit(0, 0)
+	SetRateLimit(1e-9, 1)
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer c.Close()
+	l := newLogger("")
+	defer l.Close()
+	if err := l.Init(WithDial("udp", c.LocalAddr().String())); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.LogE(syslog.LOG_INFO, "first"); err != nil {
+		t.Errorf("LogE(first) = %v", err)
+	}
+	if err := l.LogE(syslog.LOG_INFO, "second"); err != ErrRateLimited {
+		t.Errorf("LogE(second) = %v, want %v", err, ErrRateLimited)
+	}
+	buf := make([]byte, 1024)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := c.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.Contains(msg, "first") {
+		t.Errorf("got %q, want the first message", msg)
+	}
+	if n := l.Dropped(DropRateLimited); n != 1 {
+		t.Errorf("Dropped(DropRateLimited) = %d, want 1", n)
+	}
+}
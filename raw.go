@@ -0,0 +1,101 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"errors"
+	"log/syslog"
+	"time"
+)
+
+// errNoPRI is returned by RawSend for messages which do not start with PRI.
+var errNoPRI = errors.New("slog: raw message does not start with <PRI>")
+
+// formatter returns a connBackend which formats messages the way the backend
+// of w does it.
+func (w *writer) formatter() *connBackend {
+	w.fmtOnce.Do(func() {
+		w.fmt = newConnBackend(w.p)
+	})
+	return w.fmt
+}
+
+// AppendMessage appends a message formatted by the logger to dst. See package
+// level AppendMessage for details.
+func (l *Logger) AppendMessage(dst []byte, severity syslog.Priority, msg string) []byte {
+	w := l.w.Load()
+	if w == nil {
+		p, _ := newParams(nil)
+		w = &writer{p: p}
+	}
+	r := recordPool.Get().(*record)
+	defer r.free()
+	*r = record{time: time.Now(), severity: severity & severityMask, msg: msg}
+	dst, _ = w.formatter().appendFrame(dst, &w.p, r)
+	return dst
+}
+
+// RawSend sends a message built by the caller, see package level RawSend for
+// details.
+func (l *Logger) RawSend(msg []byte) error {
+	severity, ok := parsePRI(msg)
+	if !ok {
+		return errNoPRI
+	}
+	r := recordPool.Get().(*record)
+	defer r.free()
+	*r = record{time: time.Now(), severity: severity, raw: msg}
+	return l.send(l.w.Load(), r)
+}
+
+// parsePRI returns the severity of msg starting with PRI part.
+func parsePRI(msg []byte) (syslog.Priority, bool) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, false
+	}
+	n := 0
+	for i, c := range msg[1:] {
+		switch {
+		case c == '>' && i > 0:
+			return syslog.Priority(n) & severityMask, true
+		case c < '0' || c > '9' || i == 3:
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return 0, false
+}
+
+// AppendMessage appends a message with the given severity formatted the way
+// the default logger formats messages, with PRI, header and msg, to dst and
+// returns the extended buffer. Attributes of the logger and options which
+// rewrite messages, e.g. WithPrefixTemplate and WithRedactRegexp, are not applied.
+// Together with RawSend it lets programs build messages in their own
+// buffers, e.g. to format them once and send them many times. Facility bits
+// of severity, if any, are ignored.
+func AppendMessage(dst []byte, severity syslog.Priority, msg string) []byte {
+	return std.AppendMessage(dst, severity, msg)
+}
+
+// RawSend sends msg built by the caller, e.g. with AppendMessage, to syslog
+// as is except for framing needed by the connection like octet counting.
+// msg must start with PRI part, e.g. "<14>". Severity and filters of the
+// logger are not applied to it and too long messages are truncated rather
+// than split. The caller may reuse msg once RawSend returns. Like LogE,
+// RawSend returns an error if the message has not been delivered to syslog
+// and has been written to the default log instead.
+func RawSend(msg []byte) error {
+	return std.RawSend(msg)
+}
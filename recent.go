@@ -0,0 +1,163 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"io"
+	"log/syslog"
+	"sync"
+	"time"
+)
+
+// recentTime is the layout of timestamps of messages written by DumpRecent.
+const recentTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// recentEntry is a message kept in the ring buffer of recent messages.
+type recentEntry struct {
+	time     time.Time
+	severity syslog.Priority
+	text     string
+}
+
+// ring keeps the last messages of a logger, see WithRecentMessages.
+type ring struct {
+	mu      sync.Mutex
+	entries []recentEntry
+	next    int  // Index of the entry to be overwritten next.
+	full    bool // Whether all entries are used.
+}
+
+func newRing(n int) *ring {
+	return &ring{entries: make([]recentEntry, n)}
+}
+
+// add adds r to the buffer overwriting the oldest message if it is full.
+func (b *ring) add(r *record) {
+	e := recentEntry{time: r.time, severity: r.severity, text: r.text()}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[b.next] = e
+	if b.next++; b.next == len(b.entries) {
+		b.next, b.full = 0, true
+	}
+}
+
+// snapshot returns the messages in the buffer, oldest first.
+func (b *ring) snapshot() []recentEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]recentEntry(nil), b.entries[:b.next]...)
+	}
+	return append(append([]recentEntry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+}
+
+// setRecent replaces the ring buffer of recent messages with one of n
+// messages, keeping the current one if it has the same size.
+func (l *Logger) setRecent(n int) {
+	if n <= 0 {
+		l.recent.Store(nil)
+		return
+	}
+	if b := l.recent.Load(); b != nil && len(b.entries) == n {
+		return
+	}
+	l.recent.Store(newRing(n))
+}
+
+// remember adds a message which is not sent to syslog, e.g. because its
+// severity is disabled, to the ring buffer of recent messages if there is
+// one. The message is redacted like the ones sent.
+func (l *Logger) remember(severity syslog.Priority, msg string, attrs []Attr) {
+	b := l.recent.Load()
+	if b == nil {
+		return
+	}
+	r := l.newRecord(severity, msg, attrs)
+	if w := l.w.Load(); w != nil && w.p.redactor.enabled() {
+		w.p.redactor.redactRecord(r)
+	}
+	b.add(r)
+	r.free()
+}
+
+// recording reports whether messages are kept in the ring buffer of recent
+// messages, so the ones which are not sent have to be formatted too.
+func (l *Logger) recording() bool {
+	return l.recent.Load() != nil
+}
+
+// DumpRecent writes messages kept by the logger to w. See package level
+// DumpRecent for details.
+func (l *Logger) DumpRecent(w io.Writer) error {
+	b := l.recent.Load()
+	if b == nil {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	for _, e := range b.snapshot() {
+		buf = e.time.AppendFormat(buf[:0], recentTime)
+		buf = append(buf, ' ')
+		buf = append(buf, SeverityString(e.severity)...)
+		buf = append(buf, ' ')
+		buf = append(buf, e.text...)
+		buf = append(buf, '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// DumpRecentOnPanic is like package level DumpRecentOnPanic for the logger.
+func (l *Logger) DumpRecentOnPanic(w io.Writer) {
+	if v := recover(); v != nil {
+		l.DumpRecent(w)
+		panic(v)
+	}
+}
+
+// DumpRecent writes messages kept by the default logger according to
+// WithRecentMessages to w, oldest first, one per line with its time and
+// severity, e.g.
+//
+//	2026-01-02T15:04:05.000000Z LOG_DEBUG cache miss key=42
+//
+// Messages which have not been sent to syslog because their severity is
+// disabled or they have been filtered out are included, so postmortems can
+// see the context leading up to a failure without sending DEBUG messages to
+// syslog all the time. DumpRecent does nothing without WithRecentMessages.
+func DumpRecent(w io.Writer) error {
+	return std.DumpRecent(w)
+}
+
+// DumpRecentOnPanic writes messages kept by the default logger to w if the
+// goroutine is panicking and continues panicking. It must be deferred
+// directly, e.g.
+//
+//	func main() {
+//		defer slog.DumpRecentOnPanic(os.Stderr)
+//		...
+//	}
+//
+// See DumpRecent.
+func DumpRecentOnPanic(w io.Writer) {
+	if v := recover(); v != nil {
+		std.DumpRecent(w)
+		panic(v)
+	}
+}
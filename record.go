@@ -0,0 +1,145 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// record is a message prepared for sending.
+type record struct {
+	time     time.Time
+	severity syslog.Priority
+	msg      string
+	msgID    string
+	attrs    []Attr // Attributes including structured data elements.
+	raw      []byte // Message formatted by the caller, see RawSend.
+}
+
+// recordPool keeps records for reuse, see Logger.newRecord and record.free.
+var recordPool = sync.Pool{New: func() interface{} { return new(record) }}
+
+// free returns r to recordPool. Backends which need r after send returns,
+// like the one of WithAsync, keep a copy of it, so records can be freed as
+// soon as they are sent.
+func (r *record) free() {
+	*r = record{}
+	recordPool.Put(r)
+}
+
+// clone returns a copy of r which is not returned to recordPool.
+func (r *record) clone() *record {
+	c := *r
+	if r.raw != nil {
+		// The caller of RawSend may reuse the buffer.
+		c.raw = append([]byte(nil), r.raw...)
+	}
+	return &c
+}
+
+// text returns the message in text format, see appendTextBody.
+func (r *record) text() string {
+	if r.raw != nil {
+		return string(r.raw)
+	}
+	if len(r.attrs) == 0 {
+		return r.msg
+	}
+	return string(appendTextBody(nil, r.msg, r.attrs))
+}
+
+// appendBody appends MSG part of a message for r with attributes attrs to dst
+// in the format selected by p. Attributes may differ from the ones of r when
+// some of them are sent separately, e.g. in STRUCTURED-DATA field.
+func appendBody(dst []byte, p *params, r *record, attrs []Attr) []byte {
+	start := len(dst)
+	if p.escapeControl {
+		e := *r
+		e.msg = escapeControlString(r.msg)
+		r, attrs = &e, escapeControlAttrs(attrs)
+	}
+	return p.sanitize(appendBodyFormat(dst, p, r, attrs), start)
+}
+
+func appendBodyFormat(dst []byte, p *params, r *record, attrs []Attr) []byte {
+	switch p.body {
+	case bodyJSON:
+		return appendJSONBody(dst, r.msg, attrs)
+	case bodyLogfmt:
+		return appendLogfmtBody(dst, r.msg, attrs)
+	case bodyGELF:
+		return appendGELFBody(dst, p, r, attrs)
+	case bodyCEF:
+		return appendCEFBody(dst, p, r, attrs)
+	case bodyLEEF:
+		return appendLEEFBody(dst, p, r, attrs)
+	}
+	return appendTextBody(dst, r.msg, attrs)
+}
+
+// appendTextBody appends msg followed by attrs in key=value form to dst.
+// Structured data elements go first in RFC 5424 syntax, as a fallback for
+// formats which do not support structured data.
+func appendTextBody(dst []byte, msg string, attrs []Attr) []byte {
+	start := len(dst)
+	for _, a := range attrs {
+		if a.Value.isSD() {
+			dst = appendSD(dst, []Attr{a})
+		}
+	}
+	if len(dst) > start && msg != "" {
+		dst = append(dst, ' ')
+	}
+	dst = append(dst, msg...)
+	for _, a := range attrs {
+		if !a.Value.isSD() {
+			dst = appendAttr(dst, start, "", a)
+		}
+	}
+	return dst
+}
+
+// appendSD appends STRUCTURED-DATA in RFC 5424 syntax to dst.
+func appendSD(dst []byte, elements []Attr) []byte {
+	for _, e := range elements {
+		dst = append(dst, '[')
+		dst = append(dst, e.Key...)
+		dst = appendSDParams(dst, "", e.Value.Group())
+		dst = append(dst, ']')
+	}
+	return dst
+}
+
+func appendSDParams(dst []byte, prefix string, params []Attr) []byte {
+	for _, p := range params {
+		if p.Value.kind == KindGroup {
+			dst = appendSDParams(dst, prefix+p.Key+".", p.Value.Group())
+			continue
+		}
+		dst = append(dst, ' ')
+		dst = append(dst, prefix...)
+		dst = append(dst, p.Key...)
+		dst = append(dst, '=', '"')
+		dst = append(dst, sdEscaper.Replace(p.Value.String())...)
+		dst = append(dst, '"')
+	}
+	return dst
+}
+
+// sdEscaper escapes characters which must be escaped in PARAM-VALUE.
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
@@ -0,0 +1,159 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets removed from messages.
+const redacted = "[REDACTED]"
+
+// defaultRedactKeys are keys of attributes which usually hold secrets.
+var defaultRedactKeys = []string{
+	"password", "passwd", "pwd", "secret", "token", "access_token",
+	"refresh_token", "id_token", "api_key", "apikey", "authorization",
+	"cookie", "set-cookie", "private_key", "client_secret",
+}
+
+// defaultRedactRegexps match common shapes of credentials. Capturing groups
+// select parts to mask.
+var defaultRedactRegexps = []*regexp.Regexp{
+	// Private keys in PEM format.
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	// Authorization header values.
+	regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+([A-Za-z0-9\-._~+/]+=*)`),
+	// Passwords in URLs.
+	regexp.MustCompile(`://[^:/?#@\s]+:([^@/?#\s]+)@`),
+	// key=value and key: value pairs with secret-looking keys.
+	regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key)\s*[=:]\s*("[^"]*"|[^\s,;&]+)`),
+	// JSON Web Tokens.
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	// AWS access key IDs.
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// GitHub tokens.
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+}
+
+// redactor masks secrets and personal data in messages.
+type redactor struct {
+	keys      map[string]bool // Lower case attribute keys.
+	regexps   []*regexp.Regexp
+	detectors []Detector
+}
+
+func (rd *redactor) addKeys(keys []string) {
+	if rd.keys == nil {
+		rd.keys = make(map[string]bool, len(keys))
+	}
+	for _, k := range keys {
+		rd.keys[strings.ToLower(k)] = true
+	}
+}
+
+func (rd *redactor) enabled() bool {
+	return len(rd.keys) > 0 || len(rd.regexps) > 0 || len(rd.detectors) > 0
+}
+
+// redactRecord masks secrets and personal data in text and attributes of r.
+func (rd *redactor) redactRecord(r *record) {
+	r.msg = rd.redactText(r.msg)
+	r.attrs = rd.redactAttrs(r.attrs)
+}
+
+// redactText returns s with text matched by the regular expressions or found
+// by the detectors masked.
+func (rd *redactor) redactText(s string) string {
+	for _, re := range rd.regexps {
+		s = redactRegexp(re, s)
+	}
+	for i := range rd.detectors {
+		s = rd.detectors[i].scrub(s)
+	}
+	return s
+}
+
+// redactRegexp returns s with matches of re masked. If re has capturing
+// groups, only the text matched by them is masked.
+func redactRegexp(re *regexp.Regexp, s string) string {
+	ms := re.FindAllStringSubmatchIndex(s, -1)
+	if ms == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range ms {
+		groups := m[2:]
+		if len(groups) == 0 {
+			groups = m[:2]
+		}
+		for i := 0; i < len(groups); i += 2 {
+			if groups[i] < last {
+				// Unmatched or nested group.
+				continue
+			}
+			b.WriteString(s[last:groups[i]])
+			b.WriteString(redacted)
+			last = groups[i+1]
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// redactAttrs returns attrs with values of secret keys and text in values
+// found by redactText masked. It copies attrs only if needed.
+func (rd *redactor) redactAttrs(attrs []Attr) []Attr {
+	var out []Attr
+	for i, a := range attrs {
+		v, ok := rd.redactValue(a.Key, a.Value)
+		if out == nil {
+			if !ok {
+				continue
+			}
+			out = append(make([]Attr, 0, len(attrs)), attrs[:i]...)
+		}
+		out = append(out, Attr{a.Key, v})
+	}
+	if out == nil {
+		return attrs
+	}
+	return out
+}
+
+// redactValue returns v masked if needed and whether it has changed.
+func (rd *redactor) redactValue(key string, v Value) (Value, bool) {
+	if v.kind == KindGroup {
+		g := v.Group()
+		rg := rd.redactAttrs(g)
+		if len(g) == 0 || &rg[0] == &g[0] {
+			return v, false
+		}
+		v.any = rg
+		return v, true
+	}
+	if rd.keys[strings.ToLower(key)] {
+		return Value{kind: KindString, str: redacted}, true
+	}
+	if len(rd.regexps) == 0 && len(rd.detectors) == 0 || v.kind != KindString && v.kind != KindAny {
+		return v, false
+	}
+	s := v.String()
+	if rs := rd.redactText(s); rs != s {
+		return Value{kind: KindString, str: rs}, true
+	}
+	return v, false
+}
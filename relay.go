@@ -0,0 +1,112 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpRelayTimeout limits the time an HTTP relay is given to accept a
+// message.
+const httpRelayTimeout = 30 * time.Second
+
+// parseHTTPRelay parses the URL given to WithHTTPRelay.
+func parseHTTPRelay(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP relay: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("HTTP relay: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("HTTP relay: missing host in %q", rawURL)
+	}
+	return u, nil
+}
+
+// httpConn posts messages to the HTTP relay set with WithHTTPRelay. It is a
+// net.Conn so connBackend can use it like other connections, but there is
+// nothing to read from it.
+type httpConn struct {
+	client *http.Client
+	url    string
+}
+
+// openRelay creates an httpConn which connects to the relay the same way
+// connBackend connects to syslog service.
+func (b *connBackend) openRelay() (*httpConn, error) {
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := b.p.dialContext(ctx)
+			defer cancel()
+			return b.dial(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: 1,
+	}
+	if b.p.useTLS() {
+		config, err := b.p.clientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = config
+	}
+	return &httpConn{
+		client: &http.Client{Transport: t, Timeout: httpRelayTimeout},
+		url:    b.p.relayURL.String(),
+	}, nil
+}
+
+// Write posts msg to the relay.
+func (c *httpConn) Write(msg []byte) (int, error) {
+	// The transport may read the body after Do returns an error while msg
+	// is reused for other messages.
+	body := bytes.NewReader(append([]byte(nil), msg...))
+	req, err := http.NewRequest(http.MethodPost, c.url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("HTTP relay: %s", resp.Status)
+	}
+	return len(msg), nil
+}
+
+func (c *httpConn) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *httpConn) Read([]byte) (int, error)         { return 0, io.EOF }
+func (c *httpConn) LocalAddr() net.Addr              { return nil }
+func (c *httpConn) RemoteAddr() net.Addr             { return nil }
+func (c *httpConn) SetDeadline(time.Time) error      { return nil }
+func (c *httpConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *httpConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,238 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// relpTimeout limits the time the RELP server is given to acknowledge a
+// command.
+var relpTimeout = 30 * time.Second
+
+// relpMaxTxnr is the largest transaction number allowed by RELP, after which
+// numbering starts from 1 again.
+const relpMaxTxnr = 999999999
+
+// relpOffers are the offers sent with the open command.
+const relpOffers = "relp_version=0\nrelp_software=github.com/badrpc/slog\ncommands=syslog"
+
+// errRELPServerClose is returned when the RELP server ends the session.
+// It wraps io.EOF so the connection is treated as broken.
+var errRELPServerClose = fmt.Errorf("RELP server closed the session: %w", io.EOF)
+
+// relpConn is a connection to a RELP server. Each Write sends a syslog
+// command and returns after the server has acknowledged it.
+type relpConn struct {
+	net.Conn
+	r      *bufio.Reader
+	txnr   int
+	broken error // Set once the session cannot continue, see relpBroken.
+}
+
+// relpBroken is returned when a RELP session cannot continue, e.g. because
+// the response to a command has not arrived in time and would be taken for
+// the response to the next one. The connection has to be reopened.
+type relpBroken struct {
+	err error
+}
+
+func (e *relpBroken) Error() string { return e.err.Error() }
+func (e *relpBroken) Unwrap() error { return e.err }
+
+// relpOpen starts a RELP session over c, negotiating the syslog command.
+func relpOpen(ctx context.Context, c net.Conn) (*relpConn, error) {
+	rc := &relpConn{Conn: c, r: bufio.NewReader(c)}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(relpTimeout)
+	}
+	rsp, err := rc.commandDeadline("open", []byte(relpOffers), deadline)
+	if err == nil && !relpOffered(rsp, "commands", "syslog") {
+		err = errors.New("RELP server does not support syslog command")
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+// Write sends msg with the syslog command.
+func (c *relpConn) Write(msg []byte) (int, error) {
+	if _, err := c.command("syslog", msg); err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
+
+// Close ends the RELP session and closes the connection.
+func (c *relpConn) Close() error {
+	c.commandDeadline("close", nil, time.Now().Add(time.Second))
+	return c.Conn.Close()
+}
+
+func (c *relpConn) command(cmd string, data []byte) ([]byte, error) {
+	return c.commandDeadline(cmd, data, time.Now().Add(relpTimeout))
+}
+
+// commandDeadline sends cmd with data and waits for the response until
+// deadline. It returns the data of a successful response following the
+// status line. Errors other than a failure reported by the server break the
+// session.
+func (c *relpConn) commandDeadline(cmd string, data []byte, deadline time.Time) ([]byte, error) {
+	if c.broken != nil {
+		return nil, c.broken
+	}
+	rsp, err := c.exchange(cmd, data, deadline)
+	var failed *relpFailure
+	if err != nil && !errors.As(err, &failed) {
+		c.broken = &relpBroken{err}
+		return nil, c.broken
+	}
+	return rsp, err
+}
+
+// relpFailure is a well-formed response with a status other than 200. The
+// session can continue after it.
+type relpFailure struct {
+	cmd    string
+	status []byte
+}
+
+func (e *relpFailure) Error() string {
+	return fmt.Sprintf("RELP %s: %s", e.cmd, e.status)
+}
+
+// exchange sends cmd with data and reads the response, see commandDeadline.
+func (c *relpConn) exchange(cmd string, data []byte, deadline time.Time) ([]byte, error) {
+	c.txnr++
+	if c.txnr > relpMaxTxnr {
+		c.txnr = 1
+	}
+	frame := strconv.AppendInt(make([]byte, 0, len(data)+32), int64(c.txnr), 10)
+	frame = append(frame, ' ')
+	frame = append(frame, cmd...)
+	frame = append(frame, ' ')
+	frame = strconv.AppendInt(frame, int64(len(data)), 10)
+	if len(data) > 0 {
+		frame = append(frame, ' ')
+		frame = append(frame, data...)
+	}
+	frame = append(frame, '\n')
+
+	c.Conn.SetDeadline(deadline)
+	defer c.Conn.SetDeadline(time.Time{})
+	if _, err := c.Conn.Write(frame); err != nil {
+		return nil, err
+	}
+	txnr, rcmd, rdata, err := c.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if rcmd == "serverclose" {
+		return nil, errRELPServerClose
+	}
+	if rcmd != "rsp" {
+		return nil, fmt.Errorf("unexpected RELP command %q", rcmd)
+	}
+	if txnr != c.txnr {
+		return nil, fmt.Errorf("RELP response to transaction %d, want %d", txnr, c.txnr)
+	}
+	status, rest, _ := bytes.Cut(rdata, []byte{'\n'})
+	if !bytes.HasPrefix(status, []byte("200")) {
+		return nil, &relpFailure{cmd, status}
+	}
+	return rest, nil
+}
+
+// readFrame reads a RELP frame.
+func (c *relpConn) readFrame() (txnr int, cmd string, data []byte, err error) {
+	s, err := c.readToken()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if txnr, err = strconv.Atoi(s); err != nil {
+		return 0, "", nil, fmt.Errorf("invalid RELP transaction number %q", s)
+	}
+	if cmd, err = c.readToken(); err != nil {
+		return 0, "", nil, err
+	}
+	// Data length has at most 9 digits and is followed by a space and data
+	// or by the trailer if there is no data.
+	n := 0
+	for i := 0; ; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, "", nil, err
+		}
+		switch {
+		case b >= '0' && b <= '9' && i < 9:
+			n = n*10 + int(b-'0')
+			continue
+		case b == '\n' && i > 0 && n == 0:
+			return txnr, cmd, nil, nil
+		case b == ' ' && i > 0:
+		default:
+			return 0, "", nil, errors.New("invalid RELP data length")
+		}
+		break
+	}
+	data = make([]byte, n+1)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, "", nil, err
+	}
+	if data[n] != '\n' {
+		return 0, "", nil, errors.New("missing RELP frame trailer")
+	}
+	return txnr, cmd, data[:n], nil
+}
+
+// readToken reads the frame header field ending with a space.
+func (c *relpConn) readToken() (string, error) {
+	s, err := c.r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	if len(s) > 32 {
+		return "", errors.New("invalid RELP frame header")
+	}
+	return s[:len(s)-1], nil
+}
+
+// relpOffered reports whether offers returned by the server contain value
+// for name.
+func relpOffered(offers []byte, name, value string) bool {
+	for _, line := range bytes.Split(offers, []byte{'\n'}) {
+		k, v, _ := bytes.Cut(line, []byte{'='})
+		if string(k) != name {
+			continue
+		}
+		for _, x := range bytes.Split(v, []byte{','}) {
+			if string(x) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// relpServer is a RELP server for tests. It acknowledges the open command
+// and answers syslog commands according to reply.
+type relpServer struct {
+	ln net.Listener
+
+	// reply returns the response to the n-th syslog command received over
+	// connection number conn, both counted from 0, and how long to wait
+	// before sending it. An empty response means "rsp 200 OK" and one
+	// starting with "!" is sent as is without the "!" instead of a frame.
+	reply func(conn, n int) (string, time.Duration)
+
+	mu       sync.Mutex
+	conns    int
+	messages []string
+}
+
+func newRELPServer(t *testing.T, reply func(conn, n int) (string, time.Duration)) *relpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	s := &relpServer{ln: ln, reply: reply}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *relpServer) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		conn := s.conns
+		s.conns++
+		s.mu.Unlock()
+		go s.handle(c, conn)
+	}
+}
+
+func (s *relpServer) handle(c net.Conn, conn int) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for n := 0; ; {
+		txnr, cmd, data, err := readTestRELPFrame(r)
+		if err != nil {
+			return
+		}
+		rsp, delay := "", time.Duration(0)
+		switch cmd {
+		case "open":
+			rsp = "200 OK\ncommands=syslog"
+		case "close":
+			fmt.Fprintf(c, "%d rsp 6 200 OK\n", txnr)
+			return
+		case "syslog":
+			s.mu.Lock()
+			s.messages = append(s.messages, data)
+			s.mu.Unlock()
+			rsp, delay = s.reply(conn, n)
+			n++
+		}
+		if rsp == "" {
+			rsp = "200 OK"
+		}
+		time.Sleep(delay)
+		if raw := strings.TrimPrefix(rsp, "!"); raw != rsp {
+			io.WriteString(c, raw)
+			continue
+		}
+		fmt.Fprintf(c, "%d rsp %d %s\n", txnr, len(rsp), rsp)
+	}
+}
+
+func (s *relpServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func readTestRELPFrame(r *bufio.Reader) (int, string, string, error) {
+	header, err := r.ReadString(' ')
+	if err != nil {
+		return 0, "", "", err
+	}
+	txnr, _ := strconv.Atoi(strings.TrimSpace(header))
+	cmd, err := r.ReadString(' ')
+	if err != nil {
+		return 0, "", "", err
+	}
+	var n int
+	var sep byte
+	if _, err := fmt.Fscanf(r, "%d%c", &n, &sep); err != nil {
+		return 0, "", "", err
+	}
+	if sep == '\n' {
+		return txnr, strings.TrimSpace(cmd), "", nil
+	}
+	data := make([]byte, n+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, "", "", err
+	}
+	return txnr, strings.TrimSpace(cmd), string(data[:n]), nil
+}
+
+func TestRELP(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	defer func(t time.Duration) { relpTimeout = t }(relpTimeout)
+	relpTimeout = timeout
+
+	tests := []struct {
+		name    string
+		reply   func(conn, n int) (string, time.Duration)
+		wantErr []bool // Whether LogE fails for each message.
+	}{{
+		name:    "acknowledged",
+		reply:   func(conn, n int) (string, time.Duration) { return "", 0 },
+		wantErr: []bool{false, false, false},
+	}, {
+		name: "refused",
+		reply: func(conn, n int) (string, time.Duration) {
+			if n == 1 {
+				return "500 no space", 0
+			}
+			return "", 0
+		},
+		wantErr: []bool{false, true, false},
+	}, {
+		// The late response must not be taken for the response to the
+		// next message, so the session is started again.
+		name: "timeout",
+		reply: func(conn, n int) (string, time.Duration) {
+			if conn == 0 && n == 1 {
+				return "", 3 * timeout
+			}
+			return "", 0
+		},
+		wantErr: []bool{false, false, false, false},
+	}, {
+		name: "malformed response",
+		reply: func(conn, n int) (string, time.Duration) {
+			if conn == 0 && n == 0 {
+				return "!x rsp 6 200 OK\n", 0
+			}
+			return "", 0
+		},
+		wantErr: []bool{false, false, false},
+	}, {
+		name: "other transaction",
+		reply: func(conn, n int) (string, time.Duration) {
+			if conn == 0 && n == 0 {
+				return "!999 rsp 6 200 OK\n", 0
+			}
+			return "", 0
+		},
+		wantErr: []bool{false, false, false},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRELPServer(t, tt.reply)
+			l := newLogger("")
+			if err := l.Init(WithDial("tcp", s.ln.Addr().String()), WithRELP()); err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			for i, wantErr := range tt.wantErr {
+				err := l.LogE(syslog.LOG_INFO, "message ", i)
+				if (err != nil) != wantErr {
+					t.Errorf("message %d: got error %v, want error: %v", i, err, wantErr)
+				}
+			}
+			got := s.received()
+			if last := got[len(got)-1]; !strings.HasSuffix(last, fmt.Sprint("message ", len(tt.wantErr)-1)) {
+				t.Errorf("last message received is %q", last)
+			}
+		})
+	}
+}
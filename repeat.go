@@ -0,0 +1,143 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// repeats keeps track of the last message sent by a logger to suppress its
+// repetitions, see WithRepeatSuppression.
+type repeats struct {
+	timeout time.Duration
+
+	mu       sync.Mutex
+	severity syslog.Priority
+	msg      string
+	valid    bool   // Whether msg may be repeated.
+	n        int    // Number of suppressed repetitions.
+	gen      uint64 // Incremented when a run of repetitions ends.
+	timer    *time.Timer
+}
+
+// check records a message with severity and text msg. It reports whether the
+// message repeats the last one and has to be suppressed. Otherwise it returns
+// the number of suppressed repetitions of the last message and its severity.
+// Only plain messages, i.e. without attributes, are suppressed.
+func (rs *repeats) check(l *Logger, severity syslog.Priority, msg string, plain bool) (bool, syslog.Priority, int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if plain && rs.valid && severity == rs.severity && msg == rs.msg {
+		rs.n++
+		if rs.timer == nil {
+			gen := rs.gen
+			rs.timer = time.AfterFunc(rs.timeout, func() {
+				l.sendRepeats(rs.expire(gen))
+			})
+		}
+		return true, 0, 0
+	}
+	last, n := rs.severity, rs.n
+	rs.endLocked()
+	rs.severity, rs.msg, rs.valid = severity, msg, plain
+	return false, last, n
+}
+
+// take returns the number of suppressed repetitions of the last message and
+// its severity and starts counting them anew.
+func (rs *repeats) take() (syslog.Priority, int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	n := rs.n
+	rs.endLocked()
+	return rs.severity, n
+}
+
+// expire is like take but returns nothing if the run of repetitions numbered
+// gen has already ended.
+func (rs *repeats) expire(gen uint64) (syslog.Priority, int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if gen != rs.gen {
+		return 0, 0
+	}
+	n := rs.n
+	rs.endLocked()
+	return rs.severity, n
+}
+
+// endLocked ends the current run of repetitions. It must be called with
+// rs.mu held.
+func (rs *repeats) endLocked() {
+	if rs.timer != nil {
+		rs.timer.Stop()
+		rs.timer = nil
+	}
+	rs.n = 0
+	rs.gen++
+}
+
+// repeatedText returns the message replacing n repetitions.
+func repeatedText(n int) string {
+	return "last message repeated " + strconv.Itoa(n) + " times"
+}
+
+// setRepeats makes the logger suppress repeated messages with the given
+// timeout, keeping the current state if the timeout has not changed.
+func (l *Logger) setRepeats(timeout time.Duration) {
+	if rs := l.repeats.Load(); rs != nil && rs.timeout == timeout {
+		return
+	}
+	var rs *repeats
+	if timeout > 0 {
+		rs = &repeats{timeout: timeout}
+	}
+	if old := l.repeats.Swap(rs); old != nil {
+		l.flushRepeats(old)
+	}
+}
+
+// repeated reports whether the message repeats the last one and has to be
+// suppressed, see WithRepeatSuppression. Otherwise it sends the number of
+// suppressed repetitions of the last message first, if any.
+func (l *Logger) repeated(w *writer, severity syslog.Priority, msg string, attrs []Attr, try bool) bool {
+	rs := l.repeats.Load()
+	if rs == nil {
+		return false
+	}
+	plain := len(attrs) == 0 && len(l.fields) == 0
+	repeat, last, n := rs.check(l, severity, msg, plain)
+	if n > 0 {
+		l.emit(w, last, repeatedText(n), nil, try)
+	}
+	return repeat
+}
+
+// flushRepeats sends the number of suppressed repetitions of the last message
+// kept by rs, if any.
+func (l *Logger) flushRepeats(rs *repeats) {
+	l.sendRepeats(rs.take())
+}
+
+// sendRepeats sends the number n of suppressed repetitions of a message with
+// severity unless it is zero.
+func (l *Logger) sendRepeats(severity syslog.Priority, n int) {
+	if n > 0 {
+		l.emit(l.w.Load(), severity, repeatedText(n), nil, false)
+	}
+}
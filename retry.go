@@ -0,0 +1,107 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes delays between connection attempts. The first delay
+// is Initial and every next one is Multiplier times longer than the previous
+// one but never longer than Max. Zero fields are replaced with the values
+// from DefaultRetryPolicy.
+//
+// Jitter randomizes every delay by up to the given fraction of it in either
+// direction, e.g. 0.2 gives delays from 80% to 120% of the nominal ones, so
+// many programs losing connection at once do not reconnect in lockstep. Zero
+// Jitter means no randomization.
+type RetryPolicy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// DefaultRetryPolicy is a reasonable retry policy for connecting to a syslog
+// service which is being started at the same time as the program.
+var DefaultRetryPolicy = RetryPolicy{
+	Initial:    100 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+}
+
+func (rp RetryPolicy) first() time.Duration {
+	if rp.Initial <= 0 {
+		return DefaultRetryPolicy.Initial
+	}
+	return rp.Initial
+}
+
+func (rp RetryPolicy) next(d time.Duration) time.Duration {
+	m, limit := rp.Multiplier, rp.Max
+	if m <= 0 {
+		m = DefaultRetryPolicy.Multiplier
+	}
+	if limit <= 0 {
+		limit = DefaultRetryPolicy.Max
+	}
+	if d = time.Duration(float64(d) * m); d > limit || d <= 0 {
+		d = limit
+	}
+	return d
+}
+
+// jitter returns d randomized according to rp.Jitter.
+func (rp RetryPolicy) jitter(d time.Duration) time.Duration {
+	if rp.Jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + rp.Jitter*(2*rand.Float64()-1)))
+}
+
+// InitWithRetry is like Init but keeps trying to initialize the syslog writer
+// of the default logger according to policy until it succeeds or ctx is done.
+// Messages sent in the meantime are written to the default log.
+func InitWithRetry(ctx context.Context, policy RetryPolicy, opts ...Option) error {
+	return std.InitWithRetry(ctx, policy, opts...)
+}
+
+// InitWithRetry is like InitContext but keeps trying to initialize the syslog
+// writer of the logger according to policy until it succeeds or ctx is done.
+// When ctx is done the returned error includes the error of the last attempt.
+// Invalid options are reported without retrying.
+func (l *Logger) InitWithRetry(ctx context.Context, policy RetryPolicy, opts ...Option) error {
+	if _, err := newParams(opts); err != nil {
+		return err
+	}
+	delay := policy.first()
+	for {
+		err := l.InitContext(ctx, opts...)
+		if err == nil {
+			return nil
+		}
+		t := time.NewTimer(policy.jitter(delay))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%w (last attempt: %v)", ctx.Err(), err)
+		case <-t.C:
+		}
+		delay = policy.next(delay)
+	}
+}
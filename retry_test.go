@@ -0,0 +1,58 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInitWithRetry(t *testing.T) {
+	// The connection attempt is given up only when ctx is done.
+	hang := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		want error
+	}{
+		{"invalid options", []Option{WithDial("udp", "localhost:514"), WithRELP()}, nil},
+		{"dial hangs", []Option{WithDial("tcp", "localhost:514"), WithDialer(hang)}, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			l := newLogger("")
+			defer l.Close()
+			policy := RetryPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond}
+			err := l.InitWithRetry(ctx, policy, tt.opts...)
+			if err == nil {
+				t.Fatal("InitWithRetry succeeded")
+			}
+			if tt.want == nil && ctx.Err() != nil {
+				t.Errorf("InitWithRetry retried until ctx was done: %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("InitWithRetry = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"sync/atomic"
+	"time"
+)
+
+// samplerCounters is the number of counters of messages keyed by severity
+// and text. Messages with different keys may share a counter.
+const samplerCounters = 4096
+
+// samplingConfig holds the settings of WithSampling.
+type samplingConfig struct {
+	severity   syslog.Priority
+	interval   time.Duration
+	first      uint64
+	thereafter uint64
+	bySeverity bool
+
+	// Thresholds of WithAdaptiveSampling, zero if not set.
+	maxQueue   int
+	maxLatency time.Duration
+}
+
+// adaptive reports whether sampling applies only under pressure, see
+// WithAdaptiveSampling.
+func (c *samplingConfig) adaptive() bool {
+	return c.maxQueue > 0 || c.maxLatency > 0
+}
+
+// sampler discards messages according to WithSampling.
+type sampler struct {
+	c        samplingConfig
+	counters []samplerCounter
+	pressure atomic.Bool // Whether adaptive sampling is active.
+}
+
+// samplerCounter counts messages with the same key in the current interval.
+type samplerCounter struct {
+	resetAt atomic.Int64 // Unix time in nanoseconds ending the interval.
+	n       atomic.Uint64
+}
+
+func newSampler(c samplingConfig) *sampler {
+	n := samplerCounters
+	if c.bySeverity {
+		n = int(syslog.LOG_DEBUG) + 1
+	}
+	return &sampler{c: c, counters: make([]samplerCounter, n)}
+}
+
+// inc counts a message at time now and returns the number of messages
+// counted in the current interval.
+func (c *samplerCounter) inc(now int64, interval time.Duration) uint64 {
+	resetAt := c.resetAt.Load()
+	if now < resetAt {
+		return c.n.Add(1)
+	}
+	c.n.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, now+int64(interval)) {
+		// Another goroutine has started the interval.
+		return c.n.Add(1)
+	}
+	return 1
+}
+
+// keep reports whether a message with severity and text msg is sent.
+func (s *sampler) keep(severity syslog.Priority, msg string) bool {
+	if severity < s.c.severity {
+		return true
+	}
+	i := int(severity)
+	if !s.c.bySeverity {
+		i = int(samplerHash(severity, msg) % samplerCounters)
+	}
+	n := s.counters[i].inc(time.Now().UnixNano(), s.c.interval)
+	if n <= s.c.first {
+		return true
+	}
+	return s.c.thereafter > 0 && (n-s.c.first)%s.c.thereafter == 0
+}
+
+// samplerHash returns FNV-1a hash of severity and msg.
+func samplerHash(severity syslog.Priority, msg string) uint32 {
+	const prime = 16777619
+	h := uint32(2166136261)
+	h = (h ^ uint32(severity)) * prime
+	for i := 0; i < len(msg); i++ {
+		h = (h ^ uint32(msg[i])) * prime
+	}
+	return h
+}
+
+// active reports whether messages are sampled given the state of w. Adaptive
+// sampling starts when a threshold of WithAdaptiveSampling is reached and
+// stops when the queue and the latency fall below half of the thresholds, so
+// it does not flip on every message. Transitions are reported to the handler
+// set with WithSamplingHandler.
+func (s *sampler) active(w *writer) bool {
+	if !s.c.adaptive() {
+		return true
+	}
+	if w == nil {
+		return false
+	}
+	queue, latency := w.load()
+	on := s.pressure.Load()
+	if on {
+		if s.c.maxQueue > 0 && queue > s.c.maxQueue/2 ||
+			s.c.maxLatency > 0 && latency > s.c.maxLatency/2 {
+			return true
+		}
+	} else {
+		if (s.c.maxQueue <= 0 || queue < s.c.maxQueue) &&
+			(s.c.maxLatency <= 0 || latency < s.c.maxLatency) {
+			return false
+		}
+	}
+	if s.pressure.CompareAndSwap(on, !on) && w.p.samplingHandler != nil {
+		w.p.samplingHandler(!on)
+	}
+	return !on
+}
+
+// latencyGauge keeps a moving average of durations of sending messages, see
+// WithAdaptiveSampling.
+type latencyGauge struct {
+	avg  atomic.Int64
+	last atomic.Int64 // Unix time in nanoseconds of the last send.
+}
+
+// observe adds the duration of a send which started at start. Concurrent
+// updates may get lost, which does not matter for an average.
+func (g *latencyGauge) observe(start time.Time) {
+	now := time.Now()
+	avg := g.avg.Load()
+	g.avg.Store(avg + (int64(now.Sub(start))-avg)/8)
+	g.last.Store(now.UnixNano())
+}
+
+// load returns the average or zero if nothing has been sent for maxAge, so
+// sampling which keeps messages from being sent cannot go on forever.
+func (g *latencyGauge) load(maxAge time.Duration) time.Duration {
+	if time.Now().UnixNano()-g.last.Load() > int64(maxAge) {
+		g.avg.Store(0)
+		return 0
+	}
+	return time.Duration(g.avg.Load())
+}
+
+// setSampling replaces the sampler of the logger with one configured
+// according to c, keeping the current one and its counts if the
+// configuration has not changed.
+func (l *Logger) setSampling(c samplingConfig) {
+	if c.interval <= 0 {
+		l.sampler.Store(nil)
+		return
+	}
+	if s := l.sampler.Load(); s != nil && s.c == c {
+		return
+	}
+	l.sampler.Store(newSampler(c))
+}
+
+// sampledOut reports whether the message is discarded by sampling, see
+// WithSampling, and counts it as dropped then.
+func (l *Logger) sampledOut(w *writer, severity syslog.Priority, msg string) bool {
+	s := l.sampler.Load()
+	if s == nil || severity < s.c.severity || !s.active(w) || s.keep(severity, msg) {
+		return false
+	}
+	var p *params
+	if w != nil {
+		p = &w.p
+	}
+	l.drop(p, 1, DropSampled)
+	return true
+}
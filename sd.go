@@ -0,0 +1,166 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// SDID describes an ID of RFC 5424 structured data elements. IDs registered
+// with IANA consist of Name only. Others are private and have the form
+// "name@enterprise" where Enterprise is the private enterprise number assigned
+// by IANA to the organization defining the element, optionally followed by
+// dot-separated sub-identifiers.
+type SDID struct {
+	Name       string
+	Enterprise string
+
+	// Params lists names of parameters allowed in elements with this ID. If
+	// it is empty, any valid parameter names are allowed.
+	Params []string
+}
+
+// String returns the ID in the form used in messages.
+func (id SDID) String() string {
+	if id.Enterprise == "" {
+		return id.Name
+	}
+	return id.Name + "@" + id.Enterprise
+}
+
+var (
+	sdIDsMu sync.RWMutex
+	sdIDs   = map[string]SDID{
+		// IDs registered with IANA, RFC 5424 section 7.
+		"timeQuality": {Name: "timeQuality", Params: []string{"tzKnown", "isSynced", "syncAccuracy"}},
+		"origin":      {Name: "origin", Params: []string{"ip", "enterpriseId", "software", "swVersion"}},
+		"meta":        {Name: "meta", Params: []string{"sequenceId", "sysUpTime", "language"}},
+	}
+)
+
+// RegisterSDID registers a private structured data element ID, so ValidateSD
+// checks parameters of elements with the ID. Registering an ID again replaces
+// the previous registration. RegisterSDID returns an error if the ID or
+// parameter names are not valid according to RFC 5424 or if the ID does not
+// have an enterprise number, because IDs without it are reserved for IANA.
+func RegisterSDID(id SDID) error {
+	if id.Enterprise == "" {
+		return fmt.Errorf("SD-ID %q has no enterprise number", id.Name)
+	}
+	if err := validateSDName(id.Name); err != nil {
+		return fmt.Errorf("SD-ID %q: %v", id, err)
+	}
+	if !validEnterprise(id.Enterprise) {
+		return fmt.Errorf("SD-ID %q: invalid enterprise number %q", id, id.Enterprise)
+	}
+	for _, p := range id.Params {
+		if err := validateSDName(p); err != nil {
+			return fmt.Errorf("SD-ID %q: parameter %q: %v", id, p, err)
+		}
+	}
+	id.Params = append([]string(nil), id.Params...)
+	sdIDsMu.Lock()
+	defer sdIDsMu.Unlock()
+	sdIDs[id.String()] = id
+	return nil
+}
+
+// ValidateSD checks that a structured data element created with SD conforms
+// to RFC 5424: its ID is either registered with IANA or has a valid
+// enterprise number, parameter names are valid and allowed for registered
+// IDs (see RegisterSDID) and parameter values are valid UTF-8. Receivers may
+// discard messages with invalid structured data.
+func ValidateSD(element Attr) error {
+	if !element.Value.isSD() {
+		return fmt.Errorf("%q is not a structured data element", element.Key)
+	}
+	id := element.Key
+	if err := validateSDName(id); err != nil {
+		return fmt.Errorf("SD-ID %q: %v", id, err)
+	}
+	sdIDsMu.RLock()
+	reg, registered := sdIDs[id]
+	sdIDsMu.RUnlock()
+	if !registered {
+		at := strings.IndexByte(id, '@')
+		if at < 0 {
+			return fmt.Errorf("SD-ID %q is neither registered with IANA nor has an enterprise number", id)
+		}
+		if at == 0 || !validEnterprise(id[at+1:]) {
+			return fmt.Errorf("SD-ID %q: invalid enterprise number", id)
+		}
+	}
+	return validateSDParams(id, "", element.Value.Group(), reg.Params)
+}
+
+func validateSDParams(id, prefix string, params []Attr, allowed []string) error {
+	for _, p := range params {
+		name := prefix + p.Key
+		if p.Value.kind == KindGroup {
+			if err := validateSDParams(id, name+".", p.Value.Group(), allowed); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateSDName(name); err != nil {
+			return fmt.Errorf("SD-ID %q: parameter %q: %v", id, name, err)
+		}
+		if len(allowed) > 0 && !contains(allowed, name) {
+			return fmt.Errorf("SD-ID %q: parameter %q is not allowed", id, name)
+		}
+		if !utf8.ValidString(p.Value.String()) {
+			return fmt.Errorf("SD-ID %q: parameter %q: value is not valid UTF-8", id, name)
+		}
+	}
+	return nil
+}
+
+// validateSDName checks SD-NAME syntax, which applies to both SD-IDs and
+// parameter names: 1 to 32 printable US-ASCII characters except '=', ' ', ']'
+// and '"'.
+func validateSDName(name string) error {
+	if name == "" || len(name) > 32 {
+		return fmt.Errorf("length must be from 1 to 32 characters")
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			return fmt.Errorf("invalid character %q", c)
+		}
+	}
+	return nil
+}
+
+// validEnterprise reports whether s is a private enterprise number optionally
+// followed by dot-separated sub-identifiers, e.g. "32473" or "32473.1.2".
+func validEnterprise(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// HandleSignals makes the default logger change its minimum severity on
+// signals. See Logger.HandleSignals for details.
+func HandleSignals() (stop func()) {
+	return std.HandleSignals()
+}
+
+// HandleSignals starts a goroutine which makes the logger more verbose by one
+// severity on SIGUSR1 and less verbose by one severity on SIGUSR2. Every
+// change is reported with a LOG_NOTICE message which is sent regardless of
+// the new minimum severity. Calling stop restores the default handling of the
+// signals and stops the goroutine. It may be called more than once.
+//
+// This is useful for long-running daemons which do not expose an HTTP admin
+// port, see LevelHandler otherwise.
+func (l *Logger) HandleSignals() (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for {
+			select {
+			case s := <-c:
+				severity := l.MinSeverity()
+				switch {
+				case s == syscall.SIGUSR1 && severity < syslog.LOG_DEBUG:
+					severity++
+				case s == syscall.SIGUSR2 && severity > syslog.LOG_EMERG:
+					severity--
+				}
+				l.SetMinSeverity(severity)
+				l.write(syslog.LOG_NOTICE, "Got "+s.String()+", minimum severity is "+SeverityString(severity), nil)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	l := newLogger("")
+	l.SetMinSeverity(syslog.LOG_INFO)
+	stop := l.HandleSignals()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.MinSeverity() != syslog.LOG_DEBUG && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := l.MinSeverity(); got != syslog.LOG_DEBUG {
+		t.Errorf("minimum severity after SIGUSR1 = %v, want LOG_DEBUG", got)
+	}
+	stop()
+	stop()
+}
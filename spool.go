@@ -0,0 +1,689 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolExt is the extension of spool files, see WithSpool.
+const spoolExt = ".spool"
+
+// gzipExt is appended to names of compressed spool files, see
+// WithSpoolCompression.
+const gzipExt = ".gz"
+
+// spoolBatchSize is the number of spooled messages replayed at once.
+const spoolBatchSize = 64
+
+// spoolSegments is the number of files the spool is split into when limits
+// are set, so oldest messages can be purged file by file.
+const spoolSegments = 16
+
+// spoolSegmentSize is the size of spool files after which they are compressed
+// when there is no size limit.
+const spoolSegmentSize = 1 << 20
+
+// spoolMaxMessage is the size of the largest message which is spooled, so a
+// corrupted spool file cannot make replay allocate arbitrary amounts of
+// memory.
+const spoolMaxMessage = 16 << 20
+
+// spools holds the open spools by directory, so a writer created by Init
+// shares the spool with the one it replaces instead of both appending to and
+// replaying the same files.
+var spools = struct {
+	sync.Mutex
+	m map[string]*spool
+}{m: make(map[string]*spool)}
+
+// spooled is a formatted message with MSG part starting at body.
+type spooled struct {
+	msg  []byte
+	body int
+}
+
+// spool keeps messages which could not be sent in files in a directory until
+// they are replayed, see WithSpool. Every file holds messages as their length
+// and the position of MSG part in decimal followed by the message and a line
+// break. The position is -1 for encrypted messages, see encrypt. Files which
+// are not appended to anymore may be compressed with gzip.
+type spool struct {
+	dir      string
+	maxBytes int64         // Zero means no limit, see WithSpoolLimits.
+	maxAge   time.Duration // Zero means no limit, see WithSpoolLimits.
+	aead     cipher.AEAD   // Nil unless messages are encrypted, see WithSpoolKey.
+	compress bool          // See WithSpoolCompression.
+	purged   func(msgs int, bytes int64)
+	failed   func(error) // Reports compression errors.
+
+	refs int // Number of openSpool and attach calls not closed, protected by spools.
+
+	mu          sync.Mutex     // Protects the fields below and the files.
+	files       []spoolFile    // Oldest first.
+	f           *os.File       // Newest file messages are appended to, nil if closed.
+	offset      int64          // Position of the first message not replayed in files[0].
+	size        int64          // Total size of the files.
+	compressing bool           // Whether compressSealed is running.
+	users       []*connBackend // Connections using the spool, see attach.
+	drainer     *connBackend   // Connection replaying messages, see claimDrain.
+
+	wg sync.WaitGroup // Waits for compressSealed.
+}
+
+// spoolFile describes a spool file.
+type spoolFile struct {
+	name       string
+	size       int64
+	compressed bool
+	sealed     bool      // Whether the file is waiting to be compressed.
+	created    time.Time // Time the first message was appended.
+	modTime    time.Time // Time the last message was appended.
+}
+
+// openSpool opens the spool in dir creating dir if needed according to p.
+// Messages left by previous runs are replayed first. Messages purged because
+// of the limits are reported to purged. A spool already open in the same
+// directory is returned adjusted to p, so it must be closed by every caller.
+func openSpool(p *params, purged func(msgs int, bytes int64)) (*spool, error) {
+	dir := p.spoolDir
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	spools.Lock()
+	defer spools.Unlock()
+	if s := spools.m[dir]; s != nil {
+		s.refs++
+		s.mu.Lock()
+		s.configure(p, purged)
+		s.mu.Unlock()
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, refs: 1}
+	s.configure(p, purged)
+	// Entries are sorted by name and names are sequence numbers of the
+	// same length.
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// Left by compress when the process died.
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		seq := spoolSeq(name)
+		if seq == 0 || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		if n := len(s.files); n > 0 && spoolSeq(s.files[n-1].name) == seq {
+			// The compressed file is complete but the process died
+			// before removing the original one.
+			os.Remove(filepath.Join(s.dir, s.files[n-1].name))
+			s.size -= s.files[n-1].size
+			s.files = s.files[:n-1]
+		}
+		s.files = append(s.files, spoolFile{
+			name:       name,
+			size:       info.Size(),
+			compressed: strings.HasSuffix(name, gzipExt),
+			created:    info.ModTime(),
+			modTime:    info.ModTime(),
+		})
+		s.size += info.Size()
+	}
+	s.purge(time.Now())
+	spools.m[dir] = s
+	return s, nil
+}
+
+// configure applies the spool settings of p. It must be called with s.mu held
+// once s is shared.
+func (s *spool) configure(p *params, purged func(msgs int, bytes int64)) {
+	s.maxBytes = p.spoolMaxBytes
+	s.maxAge = p.spoolMaxAge
+	s.aead = p.spoolAEAD
+	s.compress = p.spoolCompress
+	s.purged = purged
+	s.failed = func(err error) {
+		if h := p.latest().errorHandler; h != nil {
+			h(err)
+		}
+	}
+}
+
+// spoolSeq returns the sequence number of a spool file or zero if name is not
+// a name of a spool file.
+func spoolSeq(name string) uint64 {
+	name = strings.TrimSuffix(name, gzipExt)
+	if !strings.HasSuffix(name, spoolExt) {
+		return 0
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolExt), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seq
+}
+
+// pending reports whether there are messages waiting to be replayed.
+func (s *spool) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files) > 0
+}
+
+// claimDrain reports whether connection b should replay the spooled messages
+// because no connection sharing the spool does it yet. The spool is drained
+// by one connection at a time, so messages are replayed in order, and until
+// it is empty messages sent over any connection must be spooled.
+func (s *spool) claimDrain(b *connBackend) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.drainer != nil {
+		return false
+	}
+	s.drainer = b
+	return true
+}
+
+// isDraining reports whether spooled messages are being replayed.
+func (s *spool) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drainer != nil
+}
+
+// attach registers connection b using the spool. The spool stays open until
+// b is detached.
+func (s *spool) attach(b *connBackend) {
+	spools.Lock()
+	s.refs++
+	spools.Unlock()
+	s.mu.Lock()
+	s.users = append(s.users, b)
+	s.mu.Unlock()
+}
+
+// detach unregisters connection b attached to the spool and closes it. If b
+// replays messages, the newest connection left takes over, e.g. the one of
+// the writer created by Init with the same spool.
+func (s *spool) detach(b *connBackend) error {
+	s.mu.Lock()
+	for i, u := range s.users {
+		if u == b {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			break
+		}
+	}
+	var next *connBackend
+	if s.drainer == b {
+		s.drainer = nil
+		if n := len(s.users); n > 0 && len(s.files) > 0 {
+			next = s.users[n-1]
+			s.drainer = next
+		}
+	}
+	s.mu.Unlock()
+	if next != nil {
+		go next.drain()
+	}
+	return s.close()
+}
+
+// append adds msgs to the newest spool file.
+func (s *spool) append(msgs []spooled) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.f != nil && s.full(&s.files[len(s.files)-1], now) {
+		if err := s.seal(); err != nil {
+			return err
+		}
+	}
+	if s.f == nil {
+		var seq uint64
+		if n := len(s.files); n > 0 {
+			seq = spoolSeq(s.files[n-1].name)
+		}
+		name := fmt.Sprintf("%020d%s", seq+1, spoolExt)
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		s.f = f
+		s.files = append(s.files, spoolFile{name: name, created: now})
+	}
+	var buf []byte
+	for _, m := range msgs {
+		if s.aead != nil {
+			var err error
+			if m, err = s.encrypt(m); err != nil {
+				return err
+			}
+		}
+		if len(m.msg) > spoolMaxMessage {
+			if s.purged != nil {
+				s.purged(1, int64(len(m.msg)))
+			}
+			continue
+		}
+		buf = strconv.AppendInt(buf, int64(len(m.msg)), 10)
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, int64(m.body), 10)
+		buf = append(buf, ' ')
+		buf = append(append(buf, m.msg...), '\n')
+	}
+	n, err := s.f.Write(buf)
+	last := &s.files[len(s.files)-1]
+	last.size += int64(n)
+	last.modTime = now
+	s.size += int64(n)
+	s.purge(now)
+	return err
+}
+
+// full reports whether no more messages should be appended to file f.
+func (s *spool) full(f *spoolFile, now time.Time) bool {
+	switch {
+	case s.maxBytes > 0:
+		if f.size >= s.maxBytes/spoolSegments {
+			return true
+		}
+	case s.compress:
+		if f.size >= spoolSegmentSize {
+			return true
+		}
+	}
+	return s.maxAge > 0 && now.Sub(f.created) >= s.maxAge/spoolSegments
+}
+
+// seal closes the file messages are appended to and has it compressed in
+// background if needed. It must be called with s.mu held.
+func (s *spool) seal() error {
+	err := s.f.Close()
+	s.f = nil
+	if err != nil || !s.compress {
+		return err
+	}
+	s.files[len(s.files)-1].sealed = true
+	if !s.compressing {
+		s.compressing = true
+		s.wg.Add(1)
+		go s.compressSealed()
+	}
+	return nil
+}
+
+// compressSealed compresses sealed spool files until there are none left.
+// Files are compressed without s.mu held, so messages can be spooled and
+// replayed in the meantime.
+func (s *spool) compressSealed() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		var f *spoolFile
+		for i := range s.files {
+			if s.files[i].sealed {
+				f = &s.files[i]
+				break
+			}
+		}
+		if f == nil {
+			s.compressing = false
+			s.mu.Unlock()
+			return
+		}
+		f.sealed = false
+		name := f.name
+		failed := s.failed
+		s.mu.Unlock()
+		if err := s.compressFile(name); err != nil {
+			failed(fmt.Errorf("compressing spool file: %w", err))
+		}
+	}
+}
+
+// compressFile replaces sealed spool file name with its compressed copy
+// unless the file is replayed or purged in the meantime. It must be called
+// without s.mu held.
+func (s *spool) compressFile(name string) error {
+	path := filepath.Join(s.dir, name)
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := path + gzipExt + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = os.Stat(tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var f *spoolFile
+	for i := range s.files {
+		if s.files[i].name == name {
+			f = &s.files[i]
+		}
+	}
+	if f == nil {
+		os.Remove(tmp)
+		return nil
+	}
+	if err := os.Rename(tmp, path+gzipExt); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Remove(path)
+	s.size += info.Size() - f.size
+	f.name, f.size, f.compressed = f.name+gzipExt, info.Size(), true
+	return nil
+}
+
+// purge removes the oldest files while the spool exceeds its limits. It must
+// be called with s.mu held.
+func (s *spool) purge(now time.Time) {
+	for len(s.files) > 0 {
+		oldest := s.files[0]
+		if (s.maxBytes <= 0 || s.size <= s.maxBytes) &&
+			(s.maxAge <= 0 || now.Sub(oldest.modTime) <= s.maxAge) {
+			return
+		}
+		if len(s.files) == 1 && s.f != nil {
+			s.f.Close()
+			s.f = nil
+		}
+		msgs := s.count(oldest, s.offset)
+		os.Remove(filepath.Join(s.dir, oldest.name))
+		s.files, s.offset, s.size = s.files[1:], 0, s.size-oldest.size
+		if s.purged != nil {
+			s.purged(msgs, oldest.size)
+		}
+	}
+}
+
+// read opens spool file f for reading messages starting at uncompressed
+// position offset.
+func (s *spool) read(f spoolFile, offset int64) (*bufio.Reader, io.Closer, error) {
+	file, err := os.Open(filepath.Join(s.dir, f.name))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.compressed {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return bufio.NewReader(file), file, nil
+	}
+	zr, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	r := bufio.NewReader(zr)
+	if _, err := r.Discard(int(offset)); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return r, file, nil
+}
+
+// count returns the number of messages in spool file f starting at offset.
+func (s *spool) count(f spoolFile, offset int64) int {
+	r, c, err := s.read(f, offset)
+	if err != nil {
+		return 0
+	}
+	defer c.Close()
+	n := 0
+	for {
+		if _, _, err := readSpooled(r); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+// replay reads up to n messages from the oldest spool file and passes them to
+// write. The messages are removed from the spool if write succeeds. replay
+// reports whether the spool is empty, which ends draining.
+func (s *spool) replay(n int, write func([]spooled) error) (empty bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() {
+		if empty {
+			s.drainer = nil
+		}
+	}()
+	s.purge(time.Now())
+	if len(s.files) == 0 {
+		return true, nil
+	}
+	if len(s.files) == 1 && s.f != nil {
+		// Messages spooled in the meantime go to a new file. There is no
+		// point in compressing this one.
+		s.f.Close()
+		s.f = nil
+	}
+	r, c, err := s.read(s.files[0], s.offset)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	var msgs []spooled
+	offset := s.offset
+	var rerr error
+	var rsize int64
+	for len(msgs) < n {
+		m, size, err := readSpooled(r)
+		if err != nil {
+			rerr, rsize = err, size
+			break
+		}
+		offset += size
+		if s.aead != nil {
+			m, err = s.decrypt(m)
+		} else if m.body < 0 {
+			err = errSpoolKey
+		}
+		if err != nil {
+			if s.purged != nil {
+				s.purged(1, size)
+			}
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	if len(msgs) > 0 {
+		if err := write(msgs); err != nil {
+			return false, err
+		}
+	}
+	if rerr == nil {
+		s.offset = offset
+		return false, nil
+	}
+	oldest := s.files[0]
+	s.files, s.offset, s.size = s.files[1:], 0, s.size-oldest.size
+	err = os.Remove(filepath.Join(s.dir, oldest.name))
+	if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+		// Either the end of the file or a message cut off when the
+		// process died.
+		return len(s.files) == 0, err
+	}
+	// Messages following a corrupted one cannot be found, so the rest of
+	// the file is lost.
+	lost, _ := io.Copy(io.Discard, r)
+	if s.purged != nil {
+		s.purged(1, rsize+lost)
+	}
+	return len(s.files) == 0, fmt.Errorf("%s: %w", oldest.name, rerr)
+}
+
+// newSpoolAEAD returns AES-GCM cipher with key, see WithSpoolKey.
+func newSpoolAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("spool key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts m. The encrypted message consists of a random nonce followed
+// by the position of MSG part in decimal, a space and the message sealed
+// together, so the position is not revealed either. It must be called with
+// s.mu held.
+func (s *spool) encrypt(m spooled) (spooled, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(m.msg)+32)
+	if _, err := rand.Read(nonce); err != nil {
+		return spooled{}, err
+	}
+	plain := strconv.AppendInt(nil, int64(m.body), 10)
+	plain = append(append(plain, ' '), m.msg...)
+	return spooled{msg: s.aead.Seal(nonce, nonce, plain, nil), body: -1}, nil
+}
+
+// decrypt decrypts m encrypted by encrypt. It must be called with s.mu held.
+func (s *spool) decrypt(m spooled) (spooled, error) {
+	n := s.aead.NonceSize()
+	if m.body >= 0 || len(m.msg) < n {
+		return spooled{}, errSpoolKey
+	}
+	plain, err := s.aead.Open(nil, m.msg[:n], m.msg[n:], nil)
+	if err != nil {
+		return spooled{}, errSpoolKey
+	}
+	i := bytes.IndexByte(plain, ' ')
+	if i < 0 {
+		return spooled{}, errBadSpool
+	}
+	body, err := strconv.Atoi(string(plain[:i]))
+	if err != nil || body < 0 || body > len(plain)-i-1 {
+		return spooled{}, errBadSpool
+	}
+	return spooled{plain[i+1:], body}, nil
+}
+
+var (
+	// errBadSpool is returned for malformed messages in spool files.
+	errBadSpool = errors.New("slog: malformed spooled message")
+
+	// errSpoolKey is returned for spooled messages encrypted with another
+	// key or not encrypted when they should be, see WithSpoolKey.
+	errSpoolKey = errors.New("slog: spooled message encrypted with another key")
+)
+
+// readSpooled reads a message from a spool file and returns it with the number
+// of bytes read, which is returned on errors too.
+func readSpooled(r *bufio.Reader) (spooled, int64, error) {
+	var read int64
+	readInt := func() (int, error) {
+		s, err := r.ReadSlice(' ')
+		read += int64(len(s))
+		if err == bufio.ErrBufferFull {
+			return 0, errBadSpool
+		}
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(string(s[:len(s)-1]))
+		if err != nil || n < -1 {
+			return 0, errBadSpool
+		}
+		return n, nil
+	}
+	size, err := readInt()
+	if err == nil && (size < 0 || size > spoolMaxMessage) {
+		err = errBadSpool
+	}
+	if err != nil {
+		return spooled{}, read, err
+	}
+	body, err := readInt()
+	if err != nil {
+		return spooled{}, read, err
+	}
+	msg := make([]byte, size+1)
+	n, err := io.ReadFull(r, msg)
+	read += int64(n)
+	if err != nil {
+		return spooled{}, read, err
+	}
+	if msg[size] != '\n' || body > size {
+		return spooled{}, read, errBadSpool
+	}
+	return spooled{msg[:size], body}, read, nil
+}
+
+// close releases the spool. Once it is not used anymore, close closes the
+// file messages are appended to and waits for files to be compressed.
+// Spooled messages are kept for the next run.
+func (s *spool) close() error {
+	spools.Lock()
+	defer spools.Unlock()
+	if s.refs--; s.refs > 0 {
+		return nil
+	}
+	delete(spools.m, s.dir)
+	s.mu.Lock()
+	var err error
+	if s.f != nil {
+		err = s.seal()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replayAll replays all messages of s and returns them with the first error.
+func replayAll(t *testing.T, s *spool) ([]string, error) {
+	t.Helper()
+	var got []string
+	var first error
+	for i := 0; i < 100; i++ {
+		empty, err := s.replay(spoolBatchSize, func(ms []spooled) error {
+			for _, m := range ms {
+				got = append(got, string(m.msg))
+			}
+			return nil
+		})
+		if first == nil {
+			first = err
+		}
+		if empty {
+			return got, first
+		}
+	}
+	t.Fatal("spool is not empty after 100 replays")
+	return nil, nil
+}
+
+func TestSpoolRoundTrip(t *testing.T) {
+	aead, err := newSpoolAEAD([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		compress bool
+		aead     bool
+		maxBytes int64
+	}{
+		{"plain", false, false, 0},
+		{"encrypted", false, true, 0},
+		{"compressed", true, false, 0},
+		{"compressed and encrypted", true, true, 0},
+		{"compressed segments", true, false, 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := params{spoolDir: t.TempDir(), spoolCompress: tt.compress, spoolMaxBytes: tt.maxBytes}
+			if tt.aead {
+				p.spoolAEAD = aead
+			}
+			var want []string
+			// Every run leaves a file for the next one.
+			for run := 0; run < 3; run++ {
+				s, err := openSpool(&p, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var msgs []spooled
+				for i := 0; i < 1000; i++ {
+					m := fmt.Sprintf("<14>1 - host app - - - run %d message %d", run, i)
+					msgs = append(msgs, spooled{[]byte(m), strings.Index(m, "run")})
+					want = append(want, m)
+				}
+				if err := s.append(msgs); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			names, _ := filepath.Glob(filepath.Join(p.spoolDir, "*"))
+			if len(names) < 3 {
+				t.Errorf("spool files = %q, want at least 3", names)
+			}
+			for _, name := range names {
+				if got := strings.HasSuffix(name, gzipExt); got != tt.compress {
+					t.Errorf("%s compressed = %v, want %v", name, got, tt.compress)
+				}
+			}
+			s, err := openSpool(&p, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := replayAll(t, s)
+			if err != nil {
+				t.Errorf("replay: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("replayed %q, want %q", got, want)
+			}
+			if names, _ := filepath.Glob(filepath.Join(p.spoolDir, "*")); len(names) != 0 {
+				t.Errorf("spool files left after replay: %q", names)
+			}
+		})
+	}
+}
+
+func TestSpoolCorruption(t *testing.T) {
+	const good = "5 0 first\n"
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		purged  int64
+	}{
+		{"cut off length", good + "12", false, 0},
+		{"cut off message", good + "5 0 sec", false, 0},
+		{"missing line break", good + "6 0 secondX", true, 11},
+		{"bad length", good + "x 0 second\n", true, 11},
+		{"negative length", good + "-1 0 second\n", true, 12},
+		{"huge length", good + "99999999999 0 second\n", true, 21},
+		{"body after end", good + "6 7 second\n", true, 11},
+		{"no separator", good + strings.Repeat("x", 10000), true, 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{
+				fmt.Sprintf("%020d%s", 1, spoolExt): tt.data,
+				fmt.Sprintf("%020d%s", 2, spoolExt): "4 0 next\n",
+			}
+			for name, data := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var purged int64
+			s, err := openSpool(&params{spoolDir: dir}, func(msgs int, bytes int64) { purged += bytes })
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := replayAll(t, s)
+			if gotErr := errors.Is(err, errBadSpool); gotErr != tt.wantErr {
+				t.Errorf("replay error = %v, want errBadSpool: %v", err, tt.wantErr)
+			}
+			if want := []string{"first", "next"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("replayed %q, want %q", got, want)
+			}
+			if purged != tt.purged {
+				t.Errorf("purged %d bytes, want %d", purged, tt.purged)
+			}
+		})
+	}
+}
+
+func TestSpoolSharedByBackends(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	p, err := newParams([]Option{
+		WithDial("tcp", addr),
+		WithSpool(t.TempDir()),
+		WithReconnect(RetryPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond}),
+		WithErrorHandler(func(error) {}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both backends are alive while Init replaces the writer.
+	var bs [2]backend
+	for i := range bs {
+		b, err := connect(context.Background(), p, func(*params, int, DropReason) {}, func(*params, int, int64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.close()
+		bs[i] = b
+	}
+	const n = 30
+	for i := 0; i < n; i++ {
+		r := &record{time: time.Now(), severity: syslog.LOG_INFO, msg: fmt.Sprintf("message %d", i)}
+		if err := bs[i/10%2].send(&p, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectReplayed(t, addr, n)
+}
+
+// expectReplayed listens at addr and checks that messages "message 0" to
+// "message n-1" are replayed there once and in order.
+func expectReplayed(t *testing.T, addr string, n int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 2*n)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			go func() {
+				s := bufio.NewScanner(c)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		select {
+		case line := <-lines:
+			if want := fmt.Sprintf("message %d", i); !strings.HasSuffix(line, want) {
+				t.Fatalf("got %q, want %q", line, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d not replayed", i)
+		}
+	}
+	select {
+	case line := <-lines:
+		t.Errorf("%q replayed twice", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
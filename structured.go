@@ -0,0 +1,272 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"log/syslog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alertw sends a syslog message with severity LOG_ALERT and key-value pairs, see
+// Logw.
+func Alertw(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_ALERT, msg, keysAndValues)
+}
+
+// Critw sends a syslog message with severity LOG_CRIT and key-value pairs, see
+// Logw.
+func Critw(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_CRIT, msg, keysAndValues)
+}
+
+// Debugw sends a syslog message with severity LOG_DEBUG and key-value pairs, see
+// Logw.
+func Debugw(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_DEBUG, msg, keysAndValues)
+}
+
+// Emergw sends a syslog message with severity LOG_EMERG and key-value pairs, see
+// Logw.
+func Emergw(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_EMERG, msg, keysAndValues)
+}
+
+// Errw sends a syslog message with severity LOG_ERR and key-value pairs, see
+// Logw.
+func Errw(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_ERR, msg, keysAndValues)
+}
+
+// Infow sends a syslog message with severity LOG_INFO and key-value pairs, see
+// Logw.
+func Infow(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_INFO, msg, keysAndValues)
+}
+
+// Noticew sends a syslog message with severity LOG_NOTICE and key-value pairs, see
+// Logw.
+func Noticew(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_NOTICE, msg, keysAndValues)
+}
+
+// Warningw sends a syslog message with severity LOG_WARNING and key-value pairs, see
+// Logw.
+func Warningw(msg string, keysAndValues ...interface{}) {
+	std.logw(syslog.LOG_WARNING, msg, keysAndValues)
+}
+
+// Logw sends a syslog message with the given severity and key-value pairs,
+// see Logger.Logw.
+func Logw(severity syslog.Priority, msg string, keysAndValues ...interface{}) {
+	std.logw(severity&severityMask, msg, keysAndValues)
+}
+
+// Alertw sends a syslog message with severity LOG_ALERT and key-value pairs, see
+// Logw.
+func (l *Logger) Alertw(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_ALERT, msg, keysAndValues)
+}
+
+// Critw sends a syslog message with severity LOG_CRIT and key-value pairs, see
+// Logw.
+func (l *Logger) Critw(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_CRIT, msg, keysAndValues)
+}
+
+// Debugw sends a syslog message with severity LOG_DEBUG and key-value pairs, see
+// Logw.
+func (l *Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_DEBUG, msg, keysAndValues)
+}
+
+// Emergw sends a syslog message with severity LOG_EMERG and key-value pairs, see
+// Logw.
+func (l *Logger) Emergw(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_EMERG, msg, keysAndValues)
+}
+
+// Errw sends a syslog message with severity LOG_ERR and key-value pairs, see
+// Logw.
+func (l *Logger) Errw(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_ERR, msg, keysAndValues)
+}
+
+// Infow sends a syslog message with severity LOG_INFO and key-value pairs, see
+// Logw.
+func (l *Logger) Infow(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_INFO, msg, keysAndValues)
+}
+
+// Noticew sends a syslog message with severity LOG_NOTICE and key-value pairs, see
+// Logw.
+func (l *Logger) Noticew(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_NOTICE, msg, keysAndValues)
+}
+
+// Warningw sends a syslog message with severity LOG_WARNING and key-value pairs, see
+// Logw.
+func (l *Logger) Warningw(msg string, keysAndValues ...interface{}) {
+	l.logw(syslog.LOG_WARNING, msg, keysAndValues)
+}
+
+// Logw sends a syslog message with the given severity and key-value pairs
+// appended to msg, e.g.
+//
+//	l.Logw(syslog.LOG_INFO, "request served", "path", path, "status", 200)
+//
+// sends "request served path=/index.html status=200". Keys must be strings,
+// values are formatted with fmt.Sprint. An Attr can be used in place of a key
+// and its value. Values containing spaces, quotes, '='
+// or control characters as well as empty ones are quoted with strconv.Quote,
+// so the pairs can be parsed reliably. Facility bits of severity, if any, are
+// ignored.
+func (l *Logger) Logw(severity syslog.Priority, msg string, keysAndValues ...interface{}) {
+	l.logw(severity&severityMask, msg, keysAndValues)
+}
+
+// logw renders key-value pairs and sends a message unless severity is
+// disabled.
+func (l *Logger) logw(severity syslog.Priority, msg string, keysAndValues []interface{}) {
+	if l.Enabled(severity) {
+		l.write(severity, msg, attrsOf(keysAndValues))
+	} else if l.recording() {
+		l.remember(severity, msg, attrsOf(keysAndValues))
+	}
+}
+
+// badKey is used in place of keys which are not strings or have no value.
+const badKey = "!BADKEY"
+
+// nextAttr returns the attribute which starts at keysAndValues[i] and index
+// of the next one.
+func nextAttr(keysAndValues []interface{}, i int) (Attr, int) {
+	switch x := keysAndValues[i].(type) {
+	case Attr:
+		return x, i + 1
+	case string:
+		if i+1 < len(keysAndValues) {
+			return Any(x, keysAndValues[i+1]), i + 2
+		}
+	}
+	// A lone key is more useful as a value.
+	return Any(badKey, keysAndValues[i]), i + 1
+}
+
+// attrsOf converts key-value pairs to attributes.
+func attrsOf(keysAndValues []interface{}) []Attr {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	attrs := make([]Attr, 0, len(keysAndValues)/2+1)
+	for i := 0; i < len(keysAndValues); {
+		var a Attr
+		a, i = nextAttr(keysAndValues, i)
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+// newRecord prepares a message for sending with attributes attached to the
+// logger followed by attrs. Message IDs and deduplication keys are taken out
+// of attributes.
+func (l *Logger) newRecord(severity syslog.Priority, msg string, attrs []Attr) *record {
+	r := recordPool.Get().(*record)
+	*r = record{time: time.Now(), severity: severity, msg: msg, attrs: attrs}
+	if len(l.fields) > 0 {
+		r.attrs = append(l.fields[:len(l.fields):len(l.fields)], attrs...)
+	}
+	for i, a := range r.attrs {
+		if a.Value.isMsgID() || a.Value.isDedupKey() {
+			r.takeMsgIDs(i)
+			break
+		}
+	}
+	return r
+}
+
+// takeMsgIDs moves message IDs from attributes starting at i to r.msgID and
+// removes deduplication keys, see DedupKey.
+func (r *record) takeMsgIDs(i int) {
+	attrs := append([]Attr(nil), r.attrs[:i]...)
+	for _, a := range r.attrs[i:] {
+		switch {
+		case a.Value.isMsgID():
+			r.msgID = a.Value.str
+		case a.Value.isDedupKey():
+		default:
+			attrs = append(attrs, a)
+		}
+	}
+	r.attrs = attrs
+}
+
+// appendAttr appends a to dst in key=value form, separated by a space from
+// the preceding text after start if any. Keys are prefixed with prefix.
+// Groups are flattened with their keys added to the prefix.
+func appendAttr(dst []byte, start int, prefix string, a Attr) []byte {
+	if a.Value.kind == KindGroup {
+		prefix += a.Key + "."
+		for _, ga := range a.Value.Group() {
+			dst = appendAttr(dst, start, prefix, ga)
+		}
+		return dst
+	}
+	if len(dst) > start {
+		dst = append(dst, ' ')
+	}
+	dst = append(dst, prefix...)
+	dst = append(dst, a.Key...)
+	dst = append(dst, '=')
+	if a.Value.kind == KindString || a.Value.kind == KindAny {
+		return append(dst, quoteValue(a.Value.String())...)
+	}
+	return a.Value.append(dst)
+}
+
+// quoteValue quotes v if it cannot be used in key=value form as is.
+func quoteValue(v string) string {
+	if v == "" || strings.IndexFunc(v, needsQuoting) >= 0 {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+func needsQuoting(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == '\ufffd'
+}
+
+// With returns a logger derived from the default logger which attaches fields
+// to every message, see Logger.With.
+func With(fields ...Attr) *Logger {
+	return std.With(fields...)
+}
+
+// With returns a logger which attaches fields to every message in addition to
+// the ones attached to l, e.g.
+//
+//	rl := l.With(slog.String("request_id", id))
+//	rl.Info("started") // "started request_id=..."
+//
+// The derived logger shares connection and all settings with l, so changing
+// them for one of the loggers, e.g. with Init or SetMinSeverity, changes them
+// for the other too.
+func (l *Logger) With(fields ...Attr) *Logger {
+	return &Logger{
+		core:   l.core,
+		fields: append(l.fields[:len(l.fields):len(l.fields)], fields...),
+	}
+}
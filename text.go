@@ -0,0 +1,162 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// bom is the byte order mark which starts MSG part of RFC 5424 messages known
+// to be encoded in UTF-8.
+const bom = "\xef\xbb\xbf"
+
+// ansiEscape matches ANSI CSI sequences, e.g. colors, and OSC sequences,
+// e.g. terminal titles.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// sanitize cleans up the text appended to dst after start according to p.
+// Control characters are escaped before formatting instead, see
+// escapeControlAttrs.
+func (p *params) sanitize(dst []byte, start int) []byte {
+	if p.stripANSI && bytes.IndexByte(dst[start:], 0x1b) >= 0 {
+		dst = append(dst[:start], ansiEscape.ReplaceAll(dst[start:], nil)...)
+	}
+	if (p.validUTF8 || p.bom) && !utf8.Valid(dst[start:]) {
+		dst = append(dst[:start], bytes.ToValidUTF8(dst[start:], []byte("\ufffd"))...)
+	}
+	return dst
+}
+
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// escapeControl returns a copy of b with ASCII control characters replaced
+// with escape sequences, e.g. \n or \x1b.
+func escapeControl(b []byte) []byte {
+	e := make([]byte, 0, len(b)+16)
+	for _, c := range b {
+		switch {
+		case c == '\n':
+			e = append(e, '\\', 'n')
+		case c == '\r':
+			e = append(e, '\\', 'r')
+		case c == '\t':
+			e = append(e, '\\', 't')
+		case c < ' ' || c == 0x7f:
+			e = append(e, '\\', 'x', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			e = append(e, c)
+		}
+	}
+	return e
+}
+
+// escapeControlString is like escapeControl but takes a string, which is
+// returned as is if there is nothing to escape.
+func escapeControlString(s string) string {
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+	return string(escapeControl([]byte(s)))
+}
+
+// escapeControlAttrs returns a copy of attrs with control characters in keys
+// and values escaped, see escapeControl. They are escaped before the message
+// is formatted, so separators added by formats like LEEF stay intact.
+func escapeControlAttrs(attrs []Attr) []Attr {
+	if len(attrs) == 0 {
+		return attrs
+	}
+	e := make([]Attr, len(attrs))
+	for i, a := range attrs {
+		a.Key = escapeControlString(a.Key)
+		switch a.Value.kind {
+		case KindString:
+			a.Value.str = escapeControlString(a.Value.str)
+		case KindGroup:
+			a.Value.any = escapeControlAttrs(a.Value.Group())
+		case KindAny:
+			if s := a.Value.String(); strings.IndexFunc(s, isControl) >= 0 {
+				a.Value = Value{kind: KindString, str: escapeControlString(s)}
+			}
+		}
+		e[i] = a
+	}
+	return e
+}
+
+// normalizeAttrs returns a copy of attrs with keys and string values
+// transformed with normalize.
+func normalizeAttrs(attrs []Attr, normalize func(string) string) []Attr {
+	if len(attrs) == 0 {
+		return attrs
+	}
+	n := make([]Attr, len(attrs))
+	for i, a := range attrs {
+		a.Key = normalize(a.Key)
+		switch a.Value.kind {
+		case KindString:
+			a.Value.str = normalize(a.Value.str)
+		case KindGroup:
+			a.Value.any = normalizeAttrs(a.Value.Group(), normalize)
+		}
+		n[i] = a
+	}
+	return n
+}
+
+// newlinePolicy is the way line breaks in message text are handled.
+type newlinePolicy int
+
+const (
+	// newlineKeep sends line breaks as is.
+	newlineKeep newlinePolicy = iota
+
+	// newlineEscape replaces line breaks with \n escape sequences.
+	newlineEscape
+
+	// newlineReplace replaces line breaks with a separator.
+	newlineReplace
+
+	// newlineSplit sends every line in a separate message.
+	newlineSplit
+)
+
+var newlineEscaper = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// appendLines appends text of messages to send for msg according to the
+// newline policy of p to dst. Trailing line breaks are dropped unless they are
+// kept.
+func (p *params) appendLines(dst []string, msg string) []string {
+	if p.newlines == newlineKeep || strings.IndexAny(msg, "\r\n") < 0 {
+		return append(dst, msg)
+	}
+	msg = strings.TrimRight(msg, "\r\n")
+	switch p.newlines {
+	case newlineEscape:
+		return append(dst, newlineEscaper.Replace(msg))
+	case newlineReplace:
+		return append(dst, strings.NewReplacer("\r\n", p.newlineSep, "\n", p.newlineSep, "\r", p.newlineSep).Replace(msg))
+	}
+	lines := strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return append(dst, lines...)
+}
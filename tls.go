@@ -0,0 +1,188 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// useTLS reports whether connections are secured with TLS.
+func (p *params) useTLS() bool {
+	return p.tlsConfig != nil || p.certFile != "" || p.caFile != "" || p.insecureTLS ||
+		p.serverName != "" || p.minTLS != 0 || len(p.cipherSuites) > 0
+}
+
+// sameTLS reports whether p and q describe the same TLS settings.
+func (p *params) sameTLS(q *params) bool {
+	return p.tlsConfig == q.tlsConfig &&
+		p.certFile == q.certFile &&
+		p.keyFile == q.keyFile &&
+		p.caFile == q.caFile &&
+		p.insecureTLS == q.insecureTLS &&
+		p.serverName == q.serverName &&
+		p.minTLS == q.minTLS &&
+		sameUint16s(p.cipherSuites, q.cipherSuites) &&
+		p.certReloadInterval == q.certReloadInterval &&
+		sameSignals(p.certReloadSignals, q.certReloadSignals)
+}
+
+// clientTLSConfig returns TLS configuration for a new connection with the
+// client certificate and CA certificates loaded, if any.
+func (p *params) clientTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	if p.tlsConfig != nil {
+		config = p.tlsConfig.Clone()
+	}
+	if p.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if p.caFile != "" {
+		pem, err := os.ReadFile(p.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", p.caFile)
+		}
+		config.RootCAs = pool
+	}
+	if p.insecureTLS {
+		config.InsecureSkipVerify = true
+	}
+	if p.serverName != "" {
+		config.ServerName = p.serverName
+	}
+	if p.minTLS != 0 {
+		config.MinVersion = p.minTLS
+	}
+	if len(p.cipherSuites) > 0 {
+		config.CipherSuites = p.cipherSuites
+	}
+	return config, nil
+}
+
+// octetCounting reports whether messages sent over a stream connection are
+// prefixed with their length rather than terminated with a line break.
+func (p *params) octetCounting() bool {
+	return p.octets || p.useTLS()
+}
+
+// tlsFiles returns contents of files with certificates and keys for change
+// detection.
+func (p *params) tlsFiles() ([]byte, error) {
+	var b []byte
+	for _, name := range [...]string{p.certFile, p.keyFile, p.caFile} {
+		if name == "" {
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, data...)
+	}
+	return b, nil
+}
+
+// watchCerts reconnects b when files with certificates and keys change,
+// checking them periodically and on signals according to b.p, until b is
+// closed.
+func (b *connBackend) watchCerts() {
+	var tick <-chan time.Time
+	if b.p.certReloadInterval > 0 {
+		t := time.NewTicker(b.p.certReloadInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	var sigs chan os.Signal
+	if len(b.p.certReloadSignals) > 0 {
+		sigs = make(chan os.Signal, 1)
+		signal.Notify(sigs, b.p.certReloadSignals...)
+		defer signal.Stop(sigs)
+	}
+	for {
+		select {
+		case <-tick:
+		case <-sigs:
+		case <-b.done:
+			return
+		}
+		err := b.reloadCerts()
+		if h := b.p.latest().errorHandler; err != nil && h != nil {
+			h(fmt.Errorf("reloading TLS certificates: %w", err))
+		}
+	}
+}
+
+// reloadCerts reconnects b if files with certificates and keys have changed
+// since the connection was established.
+func (b *connBackend) reloadCerts() error {
+	files, err := b.p.tlsFiles()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bytes.Equal(files, b.tlsFiles) {
+		return nil
+	}
+	old := b.conn
+	if old == nil {
+		// Reconnection in progress picks up the files.
+		return nil
+	}
+	if err := b.connect(context.Background()); err != nil {
+		return err
+	}
+	b.tlsFiles = files
+	old.Close()
+	return nil
+}
+
+func sameSignals(a, b []os.Signal) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameUint16s(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
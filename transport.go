@@ -0,0 +1,62 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// Transport connects to syslog service over a network registered with
+// RegisterTransport.
+type Transport struct {
+	// Dial connects to addr as passed to WithDial.
+	Dial func(ctx context.Context, addr string) (net.Conn, error)
+
+	// Datagram reports whether connections preserve message boundaries
+	// like UDP, so messages are sent without framing.
+	Datagram bool
+}
+
+var (
+	transportsMu sync.RWMutex
+	transports   = make(map[string]Transport)
+)
+
+// RegisterTransport makes t available under network name scheme, e.g.
+// "vsock", so Init connects with t when scheme is passed to WithDial as the
+// network. It is meant to be called from init functions of packages
+// implementing transports. If RegisterTransport is called twice with the
+// same scheme or if t.Dial is nil, it panics.
+func RegisterTransport(scheme string, t Transport) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t.Dial == nil {
+		panic("slog: RegisterTransport dial is nil")
+	}
+	if _, dup := transports[scheme]; dup {
+		panic("slog: RegisterTransport called twice for " + scheme)
+	}
+	transports[scheme] = t
+}
+
+// lookupTransport returns the transport registered for network.
+func lookupTransport(network string) (Transport, bool) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	t, ok := transports[network]
+	return t, ok
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import "log/syslog"
+
+// TryLog is like Log but it never blocks, so it can be used on paths where
+// logging must be strictly best effort, e.g. packet processing. It reports
+// whether the message has been accepted for sending. Messages can be sent
+// without blocking only if they are queued, see WithAsync, so without it
+// TryLog discards all messages. Discarded messages are not written to the
+// default log but they are counted, see Dropped. Messages discarded because
+// their severity is disabled are considered accepted.
+func TryLog(severity syslog.Priority, v ...interface{}) bool {
+	return std.TryLog(severity, v...)
+}
+
+// TryLogf is like Logf but it never blocks, see TryLog.
+func TryLogf(severity syslog.Priority, format string, v ...interface{}) bool {
+	return std.TryLogf(severity, format, v...)
+}
+
+// TryInfo sends a syslog message with severity LOG_INFO without blocking, see
+// TryLog.
+func TryInfo(v ...interface{}) bool {
+	return std.TryLog(syslog.LOG_INFO, v...)
+}
+
+// TryInfof sends a formatted syslog message with severity LOG_INFO without
+// blocking, see TryLog.
+func TryInfof(format string, v ...interface{}) bool {
+	return std.TryLogf(syslog.LOG_INFO, format, v...)
+}
+
+// TryLog is like Log but it never blocks. See package level TryLog for
+// details.
+func (l *Logger) TryLog(severity syslog.Priority, v ...interface{}) bool {
+	severity &= severityMask
+	if !l.Enabled(severity) {
+		if l.recording() {
+			l.remember(severity, sprint(v), nil)
+		}
+		return true
+	}
+	return l.output(severity, sprint(v), nil, true) == nil
+}
+
+// TryLogf is like Logf but it never blocks, see TryLog.
+func (l *Logger) TryLogf(severity syslog.Priority, format string, v ...interface{}) bool {
+	severity &= severityMask
+	if !l.Enabled(severity) {
+		if l.recording() {
+			l.remember(severity, sprintf(format, v), nil)
+		}
+		return true
+	}
+	return l.output(severity, sprintf(format, v), nil, true) == nil
+}
+
+// TryInfo sends a syslog message with severity LOG_INFO without blocking, see
+// TryLog.
+func (l *Logger) TryInfo(v ...interface{}) bool {
+	return l.TryLog(syslog.LOG_INFO, v...)
+}
+
+// TryInfof sends a formatted syslog message with severity LOG_INFO without
+// blocking, see TryLog.
+func (l *Logger) TryInfof(format string, v ...interface{}) bool {
+	return l.TryLogf(syslog.LOG_INFO, format, v...)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidMessage is wrapped by errors reported in strict validation mode,
+// see WithStrictValidation.
+var ErrInvalidMessage = errors.New("slog: invalid message")
+
+// validateRecord checks that text and attributes of r are valid UTF-8
+// without NUL bytes, attribute keys are valid RFC 5424 parameter names and
+// structured data elements are valid, see ValidateSD.
+func validateRecord(r *record) error {
+	if err := validateText(r.msg); err != nil {
+		return fmt.Errorf("%w: message text %v", ErrInvalidMessage, err)
+	}
+	for _, a := range r.attrs {
+		if a.Value.isSD() {
+			if err := ValidateSD(a); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+			}
+			continue
+		}
+		if err := validateAttr("", a); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+	}
+	return nil
+}
+
+func validateAttr(prefix string, a Attr) error {
+	key := prefix + a.Key
+	if a.Value.kind == KindGroup {
+		for _, ga := range a.Value.Group() {
+			if err := validateAttr(key+".", ga); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := validateSDName(key); err != nil {
+		return fmt.Errorf("attribute key %q: %v", key, err)
+	}
+	if a.Value.kind == KindString || a.Value.kind == KindAny {
+		if err := validateText(a.Value.String()); err != nil {
+			return fmt.Errorf("attribute %q: value %v", key, err)
+		}
+	}
+	return nil
+}
+
+func validateText(s string) error {
+	if !utf8.ValidString(s) {
+		return errors.New("is not valid UTF-8")
+	}
+	if strings.IndexByte(s, 0) >= 0 {
+		return errors.New("contains NUL byte")
+	}
+	return nil
+}
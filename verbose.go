@@ -0,0 +1,101 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+// Verbose is returned by V. Its methods send messages only if the verbosity
+// level passed to V is enabled. Otherwise they return right away, so
+//
+//	slog.V(2).Infof("request: %v", req)
+//
+// costs a single comparison when verbosity level 2 is disabled. Arguments are
+// still evaluated though, use Enabled to guard expensive computations.
+type Verbose struct {
+	l *Logger // Nil when the verbosity level is disabled.
+}
+
+// SetVerbosity sets verbosity level of the default logger. Messages sent via
+// V(level) are sent only if level is less than or equal to verbosity. The
+// initial verbosity is 0. It is safe to call SetVerbosity at any time.
+func SetVerbosity(level int) {
+	std.SetVerbosity(level)
+}
+
+// Verbosity returns verbosity level of the default logger.
+func Verbosity() int {
+	return std.Verbosity()
+}
+
+// V returns Verbose which sends messages to the default logger if level is
+// enabled by SetVerbosity.
+func V(level int) Verbose {
+	return std.V(level)
+}
+
+// SetVerbosity sets verbosity level of the logger, see package level
+// SetVerbosity.
+func (l *Logger) SetVerbosity(level int) {
+	l.verbosity.Store(int32(level))
+}
+
+// Verbosity returns verbosity level of the logger.
+func (l *Logger) Verbosity() int {
+	return int(l.verbosity.Load())
+}
+
+// V returns Verbose which sends messages to the logger if level is enabled by
+// SetVerbosity.
+func (l *Logger) V(level int) Verbose {
+	if int32(level) <= l.verbosity.Load() {
+		return Verbose{l}
+	}
+	return Verbose{}
+}
+
+// Enabled reports whether the verbosity level passed to V is enabled.
+func (v Verbose) Enabled() bool {
+	return v.l != nil
+}
+
+// Info sends a syslog message with severity LOG_INFO if the verbosity level
+// is enabled.
+func (v Verbose) Info(args ...interface{}) {
+	if v.l != nil {
+		v.l.Info(args...)
+	}
+}
+
+// Infof sends a formatted syslog message with severity LOG_INFO if the
+// verbosity level is enabled.
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v.l != nil {
+		v.l.Infof(format, args...)
+	}
+}
+
+// Debug sends a syslog message with severity LOG_DEBUG if the verbosity level
+// is enabled.
+func (v Verbose) Debug(args ...interface{}) {
+	if v.l != nil {
+		v.l.Debug(args...)
+	}
+}
+
+// Debugf sends a formatted syslog message with severity LOG_DEBUG if the
+// verbosity level is enabled.
+func (v Verbose) Debugf(format string, args ...interface{}) {
+	if v.l != nil {
+		v.l.Debugf(format, args...)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// severityMask selects severity bits of syslog.Priority.
+const severityMask = 0x07
+
+// ErrNotInitialized is returned by LogE and LogfE when a message is written
+// to the default log because the logger has no syslog writer, either because
+// Init has not succeeded yet or because the writer has been closed.
+var ErrNotInitialized = errors.New("slog: syslog writer is not initialized")
+
+// backend delivers messages to a syslog service. Messages are formatted
+// according to p which may differ from parameters the backend was created
+// with in settings not affecting the connection. Implementations must be safe
+// for concurrent use.
+type backend interface {
+	send(p *params, r *record) error
+	close() error
+}
+
+// batchBackend is implemented by backends which can send several messages
+// at once, see WithBatching.
+type batchBackend interface {
+	backend
+	sendBatch(p *params, rs []*record) error
+}
+
+// sendBatch sends rs with b at once if b supports it or one by one otherwise.
+func sendBatch(b backend, p *params, rs []*record) error {
+	if bb, ok := b.(batchBackend); ok {
+		return bb.sendBatch(p, rs)
+	}
+	for _, r := range rs {
+		if err := b.send(p, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dial creates a backend according to p. Messages dropped by the backend are
+// reported to drop and the ones purged from the spool to purge.
+func dial(ctx context.Context, p params, drop dropFunc, purge purgeFunc) (backend, error) {
+	var b backend = &lazyBackend{p: p, drop: drop, purge: purge}
+	if !p.lazyDial {
+		var err error
+		if b, err = connect(ctx, p, drop, purge); err != nil {
+			return nil, err
+		}
+	}
+	if p.breakerFailures > 0 {
+		b = newBreakerBackend(b, &p)
+	}
+	if p.queueSize > 0 {
+		b = newAsyncBackend(b, &p, drop)
+	}
+	return b, nil
+}
+
+// connect establishes a connection to syslog service according to p.
+// Messages dropped are reported to drop and the ones purged from the spool to
+// purge.
+func connect(ctx context.Context, p params, drop dropFunc, purge purgeFunc) (backend, error) {
+	var s *spool
+	if p.spoolDir != "" {
+		var err error
+		s, err = openSpool(&p, func(n int, bytes int64) { purge(p.latest(), n, bytes) })
+		if err != nil {
+			return nil, err
+		}
+		// Connections keep the spool open until they are closed, see
+		// spool.attach.
+		defer s.close()
+	}
+	if p.poolSize > 1 {
+		b, err := dialPool(ctx, p, s, drop)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	b, err := dialConn(ctx, p, s, drop)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// latest returns the parameters of the writer currently using the backend
+// created with p. They differ from p when Init has kept the connection, see
+// sameConnection.
+func (p *params) latest() *params {
+	if p.current != nil {
+		if q := p.current.Load(); q != nil {
+			return q
+		}
+	}
+	return p
+}
+
+// dialContext returns ctx limited by the timeout set with WithDialTimeout.
+func (p *params) dialContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.dialTimeout > 0 {
+		return context.WithTimeout(ctx, p.dialTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// stdlibFormat reports whether messages are formatted the way syslog.Writer
+// of the standard library does it, which is the case unless a format is
+// selected with WithRFC5424 or WithRFC3164.
+func (p *params) stdlibFormat() bool {
+	return p.format == formatStdlib
+}
+
+// lazyBackend connects to syslog service when the first message is sent.
+type lazyBackend struct {
+	p     params
+	drop  dropFunc
+	purge purgeFunc
+
+	mu sync.Mutex // Protects b.
+	b  backend
+}
+
+func (b *lazyBackend) send(p *params, r *record) error {
+	c, err := b.connect()
+	if err != nil {
+		return err
+	}
+	return c.send(p, r)
+}
+
+func (b *lazyBackend) sendBatch(p *params, rs []*record) error {
+	c, err := b.connect()
+	if err != nil {
+		return err
+	}
+	return sendBatch(c, p, rs)
+}
+
+// connect returns the backend connecting it first if needed.
+func (b *lazyBackend) connect() (backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.b == nil {
+		c, err := connect(context.Background(), b.p, b.drop, b.purge)
+		if err != nil {
+			return nil, err
+		}
+		b.b = c
+	}
+	return b.b, nil
+}
+
+func (b *lazyBackend) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.b == nil {
+		return nil
+	}
+	return b.b.close()
+}
+
+// writer keeps track of messages being sent to syslog so the underlying
+// connection can be closed without cutting them off.
+type writer struct {
+	b backend
+	p params // Parameters the writer was created with.
+
+	*writerState // Shared by writers using b, see Logger.InitContext.
+
+	fmtOnce sync.Once
+	fmt     *connBackend // See formatter.
+
+	latency latencyGauge // Measured for WithAdaptiveSampling only.
+}
+
+// writerState tells writers sharing a backend whether it has been closed.
+type writerState struct {
+	mu     sync.RWMutex // Held for reading while a message is being sent.
+	closed bool
+}
+
+func (w *writer) send(r *record) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrNotInitialized
+	}
+	if w.p.sampling.maxLatency > 0 && w.p.queueSize == 0 {
+		defer w.latency.observe(time.Now())
+	}
+	return w.b.send(&w.p, r)
+}
+
+// load returns the number of messages waiting in the queue of WithAsync and
+// the average time it takes to send a message. The average is reset when no
+// message has been sent within the interval of WithSampling.
+func (w *writer) load() (queue int, latency time.Duration) {
+	if a, ok := w.b.(*asyncBackend); ok {
+		return len(a.queue), a.latency.load(w.p.sampling.interval)
+	}
+	return 0, w.latency.load(w.p.sampling.interval)
+}
+
+// errWouldBlock is returned by writer.trySend when the message cannot be sent
+// without blocking.
+var errWouldBlock = errors.New("slog: sending message would block")
+
+// trySend is like send but it returns errWouldBlock instead of blocking. Only
+// messages queued with WithAsync can be sent without blocking.
+func (w *writer) trySend(r *record) error {
+	if !w.mu.TryRLock() {
+		// The writer is being closed.
+		return ErrNotInitialized
+	}
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrNotInitialized
+	}
+	if a, ok := w.b.(*asyncBackend); ok {
+		return a.trySend(&w.p, r)
+	}
+	return errWouldBlock
+}
+
+// flush waits until messages queued for sending are sent, see WithAsync.
+func (w *writer) flush(ctx context.Context) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if a, ok := w.b.(*asyncBackend); ok && !w.closed {
+		return a.flush(ctx)
+	}
+	return nil
+}
+
+// shutdown waits for messages being sent to complete and closes the
+// connection. If ctx expires first, shutdown returns ctx.Err() and the
+// connection is closed in background as soon as possible.
+func (w *writer) shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		done <- w.b.close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}